@@ -0,0 +1,70 @@
+// Command merkle-convert migrates the data stored under one merkle.Backend
+// to another, e.g. to move a node's chain data from leveldb to sqlite
+// without having to re-sync the chain.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/merkle/backends"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+func main() {
+	if len(os.Args) != 5 {
+		fmt.Fprintln(os.Stderr, "usage: merkle-convert <from-backend> <from-path> <to-backend> <to-path>")
+		os.Exit(1)
+	}
+
+	from, err := openBackend(os.Args[1], os.Args[2])
+	if err != nil {
+		log.Error("failed to open source backend: %v", err)
+		os.Exit(1)
+	}
+	defer from.Close()
+
+	to, err := openBackend(os.Args[3], os.Args[4])
+	if err != nil {
+		log.Error("failed to open destination backend: %v", err)
+		os.Exit(1)
+	}
+	defer to.Close()
+
+	n := 0
+	var putErr error
+	err = from.Iter(func(k, v []byte) bool {
+		if e := to.Put(k, v); e != nil {
+			putErr = e
+			return false
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		log.Error("conversion failed after %d keys: %v", n, err)
+		os.Exit(1)
+	}
+	if putErr != nil {
+		log.Error("conversion failed after %d keys: %v", n, putErr)
+		os.Exit(1)
+	}
+
+	log.Info("converted %d keys from %s to %s", n, os.Args[1], os.Args[3])
+}
+
+// openBackend builds the nodeconfig.StorageConfig for kind/path and opens
+// it through backends.NewBackend, the same factory a running node's
+// StorageConfig is wired through.
+func openBackend(kind, path string) (merkle.Backend, error) {
+	cfg := nodeconfig.StorageConfig{StorageBackend: kind}
+	switch kind {
+	case "leveldb":
+		cfg.LevelDB.Path = path
+	case "sqlite":
+		cfg.SQLite.Path = path
+	}
+	return backends.NewBackend(cfg)
+}
@@ -0,0 +1,36 @@
+package merkle
+
+import "errors"
+
+// Backend abstracts the persistent key/value store that backs a merkle
+// tree, so the tree logic in put.go does not need to know whether nodes
+// end up in memory, in an embedded db file, or in sqlite.
+//
+// Keys passed to a Backend are already the node/user-value identifiers
+// merkleTreeImp uses internally (node hashes and user-db keys) - a Backend
+// implementation does no interpretation of them.
+type Backend interface {
+	Get(k []byte) ([]byte, error)
+	Put(k, v []byte) error
+	Delete(k []byte) error
+
+	// Batch returns a WriteBatch that atomically applies a group of
+	// Put/Delete calls on Commit.
+	Batch() WriteBatch
+
+	// Iter calls fn for every (k,v) pair in the store, in backend-defined
+	// order. Iteration stops early if fn returns false.
+	Iter(fn func(k, v []byte) bool) error
+
+	Close() error
+}
+
+// WriteBatch accumulates a group of writes to be applied atomically.
+type WriteBatch interface {
+	Put(k, v []byte)
+	Delete(k []byte)
+	Commit() error
+}
+
+// KeyNotFoundError is returned by a Backend's Get when k is not present.
+var KeyNotFoundError = errors.New("key not found in backend")
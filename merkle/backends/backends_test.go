@@ -0,0 +1,72 @@
+package backends_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/merkle/backends"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+func TestMemBackendPutGetDelete(t *testing.T) {
+	b := backends.NewMemBackend()
+
+	if _, err := b.Get([]byte("k")); err != merkle.KeyNotFoundError {
+		t.Fatalf("expected KeyNotFoundError for missing key, got %v", err)
+	}
+
+	if err := b.Put([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	v, err := b.Get([]byte("k"))
+	if err != nil || !bytes.Equal(v, []byte("v")) {
+		t.Fatalf("expected to read back %q, got %q, err %v", "v", v, err)
+	}
+
+	if err := b.Delete([]byte("k")); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := b.Get([]byte("k")); err != merkle.KeyNotFoundError {
+		t.Fatalf("expected KeyNotFoundError after delete, got %v", err)
+	}
+}
+
+func TestMemBackendBatchIsAtomic(t *testing.T) {
+	b := backends.NewMemBackend()
+	if err := b.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	batch := b.Batch()
+	batch.Put([]byte("b"), []byte("2"))
+	batch.Delete([]byte("a"))
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if _, err := b.Get([]byte("a")); err != merkle.KeyNotFoundError {
+		t.Fatalf("expected \"a\" to be deleted by the batch")
+	}
+	if v, err := b.Get([]byte("b")); err != nil || !bytes.Equal(v, []byte("2")) {
+		t.Fatalf("expected \"b\" to be written by the batch, got %q, err %v", v, err)
+	}
+}
+
+func TestNewBackendSelectsMemByDefault(t *testing.T) {
+	b, err := backends.NewBackend(nodeconfig.StorageConfig{})
+	if err != nil {
+		t.Fatalf("NewBackend failed: %v", err)
+	}
+	defer b.Close()
+
+	if err := b.Put([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Put failed on backend returned by NewBackend: %v", err)
+	}
+}
+
+func TestNewBackendRejectsUnknownKind(t *testing.T) {
+	if _, err := backends.NewBackend(nodeconfig.StorageConfig{StorageBackend: "carrier-pigeon"}); err == nil {
+		t.Fatalf("expected an error for an unknown storage backend")
+	}
+}
@@ -0,0 +1,26 @@
+package backends
+
+import (
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// NewBackend constructs the merkle.Backend selected by cfg.StorageBackend,
+// using that backend's own sub-config for its on-disk path. This is the
+// single place StorageConfig actually gets turned into a usable Backend -
+// callers building a tree from a node's config should go through here
+// rather than picking an adapter constructor directly.
+func NewBackend(cfg nodeconfig.StorageConfig) (merkle.Backend, error) {
+	switch cfg.StorageBackend {
+	case "", "mem":
+		return NewMemBackend(), nil
+	case "leveldb":
+		return NewLevelDBBackend(cfg.LevelDB.Path)
+	case "sqlite":
+		return NewSQLiteBackend(cfg.SQLite.Path)
+	default:
+		return nil, fmt.Errorf("merkle: unknown storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,74 @@
+package backends
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+)
+
+// LevelDBBackend is a merkle.Backend backed by a LevelDB database file on
+// disk - the default durable backend.
+type LevelDBBackend struct {
+	db *leveldb.DB
+}
+
+// NewLevelDBBackend opens (creating if needed) a LevelDB database at path.
+func NewLevelDBBackend(path string) (*LevelDBBackend, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBBackend{db: db}, nil
+}
+
+func (b *LevelDBBackend) Get(k []byte) ([]byte, error) {
+	v, err := b.db.Get(k, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, merkle.KeyNotFoundError
+	}
+	return v, err
+}
+
+func (b *LevelDBBackend) Put(k, v []byte) error {
+	return b.db.Put(k, v, nil)
+}
+
+func (b *LevelDBBackend) Delete(k []byte) error {
+	return b.db.Delete(k, nil)
+}
+
+func (b *LevelDBBackend) Batch() merkle.WriteBatch {
+	return &leveldbBatch{db: b.db, batch: new(leveldb.Batch)}
+}
+
+func (b *LevelDBBackend) Iter(fn func(k, v []byte) bool) error {
+	iter := b.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Error()
+}
+
+func (b *LevelDBBackend) Close() error {
+	return b.db.Close()
+}
+
+type leveldbBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *leveldbBatch) Put(k, v []byte) {
+	b.batch.Put(k, v)
+}
+
+func (b *leveldbBatch) Delete(k []byte) {
+	b.batch.Delete(k)
+}
+
+func (b *leveldbBatch) Commit() error {
+	return b.db.Write(b.batch, nil)
+}
@@ -0,0 +1,95 @@
+// Package backends provides merkle.Backend implementations: an in-memory
+// map for tests, and on-disk engines (leveldb, sqlite) for real nodes.
+package backends
+
+import (
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+)
+
+// MemBackend is an in-memory merkle.Backend backed by a map. It is meant
+// for tests and does not persist across restarts.
+type MemBackend struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemBackend creates an empty in-memory backend.
+func NewMemBackend() *MemBackend {
+	return &MemBackend{data: make(map[string][]byte)}
+}
+
+// Get returns merkle.KeyNotFoundError if k isn't present.
+func (b *MemBackend) Get(k []byte) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	v, ok := b.data[string(k)]
+	if !ok {
+		return nil, merkle.KeyNotFoundError
+	}
+	return v, nil
+}
+
+func (b *MemBackend) Put(k, v []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data[string(k)] = v
+	return nil
+}
+
+func (b *MemBackend) Delete(k []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.data, string(k))
+	return nil
+}
+
+func (b *MemBackend) Batch() merkle.WriteBatch {
+	return &memBatch{backend: b}
+}
+
+func (b *MemBackend) Iter(fn func(k, v []byte) bool) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for k, v := range b.data {
+		if !fn([]byte(k), v) {
+			break
+		}
+	}
+	return nil
+}
+
+func (b *MemBackend) Close() error { return nil }
+
+type memOp struct {
+	k       []byte
+	v       []byte
+	deleted bool
+}
+
+type memBatch struct {
+	backend *MemBackend
+	ops     []memOp
+}
+
+func (b *memBatch) Put(k, v []byte) {
+	b.ops = append(b.ops, memOp{k: k, v: v})
+}
+
+func (b *memBatch) Delete(k []byte) {
+	b.ops = append(b.ops, memOp{k: k, deleted: true})
+}
+
+func (b *memBatch) Commit() error {
+	b.backend.mu.Lock()
+	defer b.backend.mu.Unlock()
+	for _, op := range b.ops {
+		if op.deleted {
+			delete(b.backend.data, string(op.k))
+			continue
+		}
+		b.backend.data[string(op.k)] = op.v
+	}
+	return nil
+}
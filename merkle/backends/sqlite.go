@@ -0,0 +1,121 @@
+package backends
+
+import (
+	"database/sql"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+)
+
+// SQLiteBackend is a merkle.Backend backed by a single-table sqlite
+// database. It trades some raw throughput for operators who'd rather rely
+// on sqlite's tooling (online backup, inspection with the sqlite3 CLI) than
+// an embedded engine's own format.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteBackend opens (creating if needed) a sqlite database at path.
+func NewSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS kv (k BLOB PRIMARY KEY, v BLOB)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteBackend{db: db}, nil
+}
+
+func (b *SQLiteBackend) Get(k []byte) ([]byte, error) {
+	var v []byte
+	err := b.db.QueryRow(`SELECT v FROM kv WHERE k = ?`, k).Scan(&v)
+	if err == sql.ErrNoRows {
+		return nil, merkle.KeyNotFoundError
+	}
+	return v, err
+}
+
+func (b *SQLiteBackend) Put(k, v []byte) error {
+	_, err := b.db.Exec(`INSERT OR REPLACE INTO kv (k, v) VALUES (?, ?)`, k, v)
+	return err
+}
+
+func (b *SQLiteBackend) Delete(k []byte) error {
+	_, err := b.db.Exec(`DELETE FROM kv WHERE k = ?`, k)
+	return err
+}
+
+func (b *SQLiteBackend) Batch() merkle.WriteBatch {
+	return &sqliteBatch{db: b.db}
+}
+
+func (b *SQLiteBackend) Iter(fn func(k, v []byte) bool) error {
+	rows, err := b.db.Query(`SELECT k, v FROM kv`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k, v []byte
+		if err := rows.Scan(&k, &v); err != nil {
+			return err
+		}
+		if !fn(k, v) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+type sqliteOp struct {
+	k       []byte
+	v       []byte
+	deleted bool
+}
+
+type sqliteBatch struct {
+	db  *sql.DB
+	ops []sqliteOp
+}
+
+func (b *sqliteBatch) Put(k, v []byte) {
+	b.ops = append(b.ops, sqliteOp{k: k, v: v})
+}
+
+func (b *sqliteBatch) Delete(k []byte) {
+	b.ops = append(b.ops, sqliteOp{k: k, deleted: true})
+}
+
+func (b *sqliteBatch) Commit() error {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, op := range b.ops {
+		if op.deleted {
+			if _, err := tx.Exec(`DELETE FROM kv WHERE k = ?`, op.k); err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO kv (k, v) VALUES (?, ?)`, op.k, op.v); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,243 @@
+package merkle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/merkle/pb"
+)
+
+// ProofNode is one encoded node on the path from the root to the terminal
+// node of a Proof, in the same wire format persistNode writes to the
+// backend. Storing the encoded bytes (rather than re-deriving them from
+// live Node objects) lets VerifyProof recompute hashes without any access
+// to the tree itself.
+type ProofNode struct {
+	Encoded []byte
+}
+
+// Proof is the ordered list of nodes on the path from a tree's root down to
+// either the node holding a key (inclusion) or the branch/ext node where
+// the search for it terminates (exclusion). A key's value can be stored
+// either on a terminal leaf or, when the key's whole path ends exactly at a
+// branch, directly on that branch (see upsert). When whichever of those
+// stores a hash of the user value rather than the value itself (see Put),
+// Value carries the original value so VerifyProof can hash-check it.
+type Proof struct {
+	Nodes []ProofNode
+	Value []byte // set only when the terminal leaf or branch stores sha256(v) instead of v
+}
+
+// ProofVerificationError is returned by VerifyProof when the supplied proof
+// does not recompute to root.
+var ProofVerificationError = errors.New("proof does not verify against the supplied root")
+
+// Prove returns a Proof for k: a path of encoded nodes a remote client can
+// use, together with VerifyProof and a trusted root hash, to confirm
+// whether k maps to v in the tree without trusting this node.
+func (mt *merkleTreeImp) Prove(k []byte) (*Proof, error) {
+
+	hexKey := hex.EncodeToString(k)
+
+	_, stack, err := mt.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	if stack.len() == 0 {
+		return nil, errors.New("empty tree has no proof")
+	}
+
+	// toSlice lists the stack head first - the deepest node first and the
+	// root last (see Put) - while a Proof runs from the root down.
+	items := stack.toSlice()
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	proof := &Proof{Nodes: make([]ProofNode, 0, len(items))}
+
+	for _, n := range items {
+		data, err := n.marshal()
+		if err != nil {
+			return nil, err
+		}
+		proof.Nodes = append(proof.Nodes, ProofNode{Encoded: data})
+	}
+
+	// if the terminal node is a leaf whose path fully matches the rest of k,
+	// or a branch at which the whole key matched (see upsert's "whole path
+	// matched" case), and the stored value looks like a sha256 digest of a
+	// larger value, attach the original value from the user db so the
+	// verifier can hash-check it.
+	last := items[len(items)-1]
+	matched := matchedLength(items[:len(items)-1])
+	if last.isLeaf() && matched <= len(hexKey) {
+		leafPath := last.getShortNode().getPath()
+		if commonPrefix(leafPath, hexKey[matched:]) == leafPath {
+			stored := last.getShortNode().getValue()
+			if len(stored) == 32 {
+				if v, err := mt.getUserValue(stored); err == nil {
+					proof.Value = v
+				}
+			}
+		}
+	} else if last.isBranch() && matched == len(hexKey) {
+		stored := last.getBranchNode().getValue()
+		if len(stored) == 32 {
+			if v, err := mt.getUserValue(stored); err == nil {
+				proof.Value = v
+			}
+		}
+	}
+
+	return proof, nil
+}
+
+// matchedLength returns the number of hex chars matched by nodes (excluding
+// any leaf), same rule as getPathLength but over a plain slice - used to
+// tell whether a terminal branch in a proof was reached with the whole key
+// already matched (upsert's "whole path matched at a branch" case).
+func matchedLength(nodes []Node) int {
+	l := 0
+	for _, n := range nodes {
+		if n.isBranch() {
+			l++
+		} else if n.isExt() {
+			l += len(n.getShortNode().getPath())
+		}
+	}
+	return l
+}
+
+// VerifyProof walks k's nibble path one proof node at a time, exactly as
+// Get does, and at every branch/extension step checks that the *specific*
+// child the path selects (the nibble at the current position, or the
+// extension's single child) hashes to the next proof node - not merely
+// that the next proof node matches *some* child of the current one. A
+// dishonest prover who supplied a sibling node at the wrong nibble would
+// fail this check even though that sibling is itself a legitimate part of
+// the tree. Having confirmed nodes[0] is root and every subsequent node is
+// specifically the one referenced by k's path, it then checks that the
+// terminal node is consistent with (k, v): for inclusion, the leaf's
+// stored value (or the hash of proof.Value, for large values) equals v;
+// for exclusion, the branch child at the next nibble is empty, or the
+// leaf/ext path diverges from k before it is fully matched.
+func VerifyProof(root []byte, k, v []byte, proof *Proof) (bool, error) {
+
+	if len(proof.Nodes) == 0 {
+		return false, errors.New("empty proof")
+	}
+
+	nodes := make([]Node, len(proof.Nodes))
+	for i, pn := range proof.Nodes {
+		n, err := unmarshalNode(pn.Encoded)
+		if err != nil {
+			return false, err
+		}
+		nodes[i] = n
+	}
+
+	rootHash, err := nodes[0].getNodeHash()
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(rootHash, root) {
+		return false, ProofVerificationError
+	}
+
+	hexKey := hex.EncodeToString(k)
+	matched := 0
+
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+
+		switch n.getNodeType() {
+		case pb.NodeType_branch:
+			if matched == len(hexKey) {
+				// whole key matched at a branch - inclusion via branch value,
+				// which may itself be a digest of a larger value (see Put);
+				// proof.Value carries the real payload the same way it does
+				// for the leaf case below.
+				stored := n.getBranchNode().getValue()
+				if proof.Value != nil {
+					return bytes.Equal(crypto.Sha256(proof.Value), stored) && bytes.Equal(proof.Value, v), nil
+				}
+				return bytes.Equal(stored, v), nil
+			}
+			idx := hexKey[matched]
+			matched++
+
+			child := n.getBranchNode().getChild(string(idx))
+			if i == len(nodes)-1 {
+				// exclusion: branch is terminal, meaning the child at idx is empty
+				return child == nil, nil
+			}
+			if err := expectChild(child, nodes[i+1]); err != nil {
+				return false, err
+			}
+
+		case pb.NodeType_extension:
+			path := n.getShortNode().getPath()
+			if len(hexKey[matched:]) < len(path) || commonPrefix(path, hexKey[matched:]) != path {
+				// exclusion: key diverges from the extension's shared path
+				return i == len(nodes)-1, nil
+			}
+			matched += len(path)
+
+			if i == len(nodes)-1 {
+				// an extension whose path fully matches k cannot be the
+				// terminal node of a well-formed proof
+				return false, ProofVerificationError
+			}
+			nextHash, err := nodes[i+1].getNodeHash()
+			if err != nil {
+				return false, err
+			}
+			if !bytes.Equal(n.getShortNode().getValue(), nextHash) {
+				return false, ProofVerificationError
+			}
+
+		case pb.NodeType_leaf:
+			path := n.getShortNode().getPath()
+			if hexKey[matched:] != path {
+				// exclusion: key diverges from the leaf's path
+				return true, nil
+			}
+			matched += len(path)
+
+			stored := n.getShortNode().getValue()
+			if proof.Value != nil {
+				return bytes.Equal(crypto.Sha256(proof.Value), stored) && bytes.Equal(proof.Value, v), nil
+			}
+			return bytes.Equal(stored, v), nil
+
+		default:
+			return false, errors.New("unexpected node type")
+		}
+	}
+
+	return false, ProofVerificationError
+}
+
+// expectChild checks that child (the node a branch's matched nibble points
+// at) is present and hashes to the same value as next, the proof node the
+// prover claims comes next on the path.
+func expectChild(child Node, next Node) error {
+	if child == nil {
+		return ProofVerificationError
+	}
+	childHash, err := child.getNodeHash()
+	if err != nil {
+		return err
+	}
+	nextHash, err := next.getNodeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(childHash, nextHash) {
+		return ProofVerificationError
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package merkle_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/merkle/backends"
+)
+
+func TestProveAndVerifyInclusion(t *testing.T) {
+	mt := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	k := []byte("key-one")
+	v := []byte("value-one")
+	if err := mt.Put(k, v); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	root, err := mt.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+
+	proof, err := mt.Prove(k)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+
+	ok, err := merkle.VerifyProof(root, k, v, proof)
+	if err != nil {
+		t.Fatalf("VerifyProof returned error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected inclusion proof to verify")
+	}
+}
+
+func TestProveAndVerifyExclusion(t *testing.T) {
+	mt := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	if err := mt.Put([]byte("key-one"), []byte("value-one")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	root, err := mt.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+
+	missingKey := []byte("key-two")
+	proof, err := mt.Prove(missingKey)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+
+	ok, err := merkle.VerifyProof(root, missingKey, nil, proof)
+	if err != nil {
+		t.Fatalf("VerifyProof returned error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected exclusion proof to verify")
+	}
+}
+
+// TestProveAndVerifyLargeValueOnTerminalBranch guards against the bug where
+// Prove/VerifyProof only piggybacked a large (>32 byte) value for a
+// terminal leaf, never for a key whose whole path lands directly on a
+// branch's own embedded value (upsert's "whole path matched" case) - that
+// case always failed verification since the branch only ever stores the
+// digest, not the real payload.
+func TestProveAndVerifyLargeValueOnTerminalBranch(t *testing.T) {
+	mt := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	// Two sibling keys sharing the 1-byte prefix 0x12, diverging at the
+	// next nibble, so the tree grows an ext+branch pair at that prefix.
+	if err := mt.Put([]byte{0x12, 0x30}, []byte("value-a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := mt.Put([]byte{0x12, 0x50}, []byte("value-b")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// A third key whose whole path is exactly that shared prefix stores its
+	// value directly on the branch, not on a leaf.
+	k := []byte{0x12}
+	v := bytes.Repeat([]byte("z"), 64) // > 32 bytes, stored as sha256(v) on the branch
+	if err := mt.Put(k, v); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	root, err := mt.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+
+	proof, err := mt.Prove(k)
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+	if proof.Value == nil {
+		t.Fatalf("expected Prove to attach the large value stored on the terminal branch")
+	}
+
+	ok, err := merkle.VerifyProof(root, k, v, proof)
+	if err != nil {
+		t.Fatalf("VerifyProof returned error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected inclusion proof for the branch-stored large value to verify")
+	}
+}
+
+// TestVerifyProofRejectsSubstitutedTerminalNode guards against the bug
+// where VerifyProof only checked that the terminal node hashed to *some*
+// child of its parent branch, rather than specifically the child at the
+// matched nibble - which let a dishonest prover splice in an unrelated
+// leaf and have it accepted as a false exclusion proof.
+func TestVerifyProofRejectsSubstitutedTerminalNode(t *testing.T) {
+	mt := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	if err := mt.Put([]byte("key-one"), []byte("value-one")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := mt.Put([]byte("key-two"), []byte("value-two")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	root, err := mt.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+
+	proof, err := mt.Prove([]byte("key-one"))
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+
+	forged, err := mt.Prove([]byte("key-two"))
+	if err != nil {
+		t.Fatalf("Prove failed: %v", err)
+	}
+
+	// Splice an unrelated leaf in as the terminal node of key-one's proof -
+	// this must not verify, neither as the (wrong) inclusion value nor as
+	// a false exclusion.
+	proof.Nodes[len(proof.Nodes)-1] = forged.Nodes[len(forged.Nodes)-1]
+
+	if ok, err := merkle.VerifyProof(root, []byte("key-one"), []byte("value-one"), proof); err == nil && ok {
+		t.Fatalf("tampered proof must not verify as a valid inclusion proof")
+	}
+	if ok, err := merkle.VerifyProof(root, []byte("key-one"), nil, proof); err == nil && ok {
+		t.Fatalf("tampered proof must not verify as a valid exclusion proof")
+	}
+}
@@ -64,62 +64,19 @@ func (mt *merkleTreeImp) Put(k, v []byte) error {
 // Update structure on the path specified by stack
 // s: stack of nodes from root leading to the value of the key. leaf as at head
 // k: key to value following the stack
+//
+// Writes all nodes on the rebuilt path through a single Backend batch (see
+// batchUpdate) rather than one persistNode call per node, so that part of a
+// path update is atomic from the backend's point of view.
 func (mt *merkleTreeImp) update(k string, s *stack) error {
 
 	log.Info("persisting nodes for path %s", k)
 
-	var lastRoot Node
-
-	var pos = len(k) - 1 // point to last hex char in k
-
 	if s.len() == 0 {
 		return nil
 	}
 
-	items := s.toSlice()
-	for i := 0; i < len(items); i++ {
-		n := items[i]
-		switch n.getNodeType() {
-
-		case pb.NodeType_branch:
-
-			if lastRoot != nil {
-
-				if pos < 0 || pos == len(k) {
-					return errors.New("invalid pos value")
-				}
-
-				idx := string(k[pos])
-				pos--
-
-				n.addBranchChild(idx, lastRoot) // this may replace old child which needs to be deleted from the db
-			}
-		case pb.NodeType_extension:
-
-			pos -= len(n.getShortNode().getPath())
-
-			if lastRoot != nil {
-				hash, err := lastRoot.getNodeHash()
-				if err != nil {
-					return err
-				}
-				n.setExtChild(hash)
-			}
-
-		case pb.NodeType_leaf:
-
-			pos -= len(n.getShortNode().getPath())
-
-		default:
-			return errors.New("unexpected node type")
-		}
-
-		lastRoot = n
-		mt.persistNode(n)
-
-	}
-
-	return nil
+	return mt.batchUpdate(k, s)
 }
 
 // Returns the number of hex chars matched by nodes in the stack
@@ -151,8 +108,7 @@ func (mt *merkleTreeImp) upsert(pos int, k string, v []byte, s *stack) error {
 			return err
 		}
 		s.push(newLeaf)
-		mt.persistNode(newLeaf)
-		return nil
+		return mt.update(k, s)
 	}
 
 	lastNode := s.pop()
@@ -231,7 +187,10 @@ func (mt *merkleTreeImp) upsert(pos int, k string, v []byte, s *stack) error {
 		lastNodePath = lastNodePath[1:]
 
 		if len(lastNodePath) > 0 || lastNode.isLeaf() {
-			// shrink ext or leaf
+			// shrink ext or leaf. lastNode isn't on the root-to-leaf path
+			// rebuilt in s, so it falls outside update's batch below and is
+			// persisted directly here - see batchUpdate's doc comment for why
+			// that's still safe.
 			mt.removeNodeFromStore(lastNode)
 			lastNode.getShortNode().setPath(lastNodePath)
 			newBranch.addBranchChild(branchChildKey, lastNode)
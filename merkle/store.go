@@ -0,0 +1,146 @@
+package merkle
+
+import (
+	"errors"
+
+	"github.com/spacemeshos/go-spacemesh/crypto"
+	"github.com/spacemeshos/go-spacemesh/merkle/pb"
+)
+
+// NewMerkleTree creates an empty merkle tree persisted through backend.
+// Callers choose which Backend to pass in via backends.NewBackend and
+// nodeconfig.Config.StorageConfig; the tree itself doesn't know or care
+// which concrete backend it's talking to.
+func NewMerkleTree(backend Backend) *merkleTreeImp {
+	return &merkleTreeImp{backend: backend}
+}
+
+// persistNode serializes n and writes it to the tree's backend, keyed by
+// its node hash.
+func (mt *merkleTreeImp) persistNode(n Node) error {
+	hash, err := n.getNodeHash()
+	if err != nil {
+		return err
+	}
+	data, err := n.marshal()
+	if err != nil {
+		return err
+	}
+	return mt.backend.Put(hash, data)
+}
+
+// persistUserValue stores a large user value (>32 bytes) under its sha256,
+// so the merkle tree itself only ever holds small, fixed-size leaves.
+// getUserValue looks it up by that same digest - the one Put embeds in the
+// tree as the leaf/branch value - so we must key the store entry on
+// crypto.Sha256(v), not on v itself.
+func (mt *merkleTreeImp) persistUserValue(v []byte) error {
+	return mt.backend.Put(userValueKey(crypto.Sha256(v)), v)
+}
+
+// removeNodeFromStore marks n for deletion. It is called from upsert, while
+// a path is still being restructured and before the replacement nodes are
+// known, so the actual Delete is deferred to batchUpdate's single Commit
+// rather than issued against the backend right away - that's what keeps the
+// rebuilt root-to-leaf path's writes and the old path's deletes atomic with
+// each other (see batchUpdate).
+func (mt *merkleTreeImp) removeNodeFromStore(n Node) error {
+	hash, err := n.getNodeHash()
+	if err != nil {
+		return err
+	}
+	mt.pendingDeletes = append(mt.pendingDeletes, hash)
+	return nil
+}
+
+// getNode loads and deserializes the node stored under hash.
+func (mt *merkleTreeImp) getNode(hash []byte) (Node, error) {
+	data, err := mt.backend.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalNode(data)
+}
+
+// getUserValue loads a large user value previously stored by
+// persistUserValue.
+func (mt *merkleTreeImp) getUserValue(hash []byte) ([]byte, error) {
+	return mt.backend.Get(userValueKey(hash))
+}
+
+func userValueKey(v []byte) []byte {
+	return append([]byte("uv:"), v...)
+}
+
+// batchUpdate writes the rebuilt root-to-leaf path (s) and the deletes
+// upsert queued via removeNodeFromStore for the nodes it replaced through a
+// single atomic Backend batch, so a crash mid-insert can never leave that
+// path half-written or a replaced node deleted before its replacement
+// lands. upsert also persists at most one sibling node directly, outside
+// this batch, when it shrinks an existing ext/leaf into a new branch
+// child (see upsert's case 4) - that write happens before the batch commits
+// and nothing references the sibling's new hash until it does, so a crash
+// between the two leaves the old tree state intact and just orphans the
+// sibling's bytes in the backend, rather than corrupting anything.
+func (mt *merkleTreeImp) batchUpdate(k string, s *stack) error {
+	batch := mt.backend.Batch()
+
+	for _, hash := range mt.pendingDeletes {
+		batch.Delete(hash)
+	}
+	mt.pendingDeletes = nil
+
+	var lastRoot Node
+	pos := len(k) - 1
+
+	if s.len() == 0 {
+		return nil
+	}
+
+	items := s.toSlice()
+	for i := 0; i < len(items); i++ {
+		n := items[i]
+		switch n.getNodeType() {
+
+		case pb.NodeType_branch:
+			if lastRoot != nil {
+				if pos < 0 || pos == len(k) {
+					return errors.New("invalid pos value")
+				}
+				idx := string(k[pos])
+				pos--
+				n.addBranchChild(idx, lastRoot)
+			}
+
+		case pb.NodeType_extension:
+			pos -= len(n.getShortNode().getPath())
+			if lastRoot != nil {
+				hash, err := lastRoot.getNodeHash()
+				if err != nil {
+					return err
+				}
+				n.setExtChild(hash)
+			}
+
+		case pb.NodeType_leaf:
+			pos -= len(n.getShortNode().getPath())
+
+		default:
+			return errors.New("unexpected node type")
+		}
+
+		hash, err := n.getNodeHash()
+		if err != nil {
+			return err
+		}
+		data, err := n.marshal()
+		if err != nil {
+			return err
+		}
+		batch.Put(hash, data)
+
+		lastRoot = n
+	}
+
+	return batch.Commit()
+}
@@ -0,0 +1,156 @@
+package merkle
+
+import (
+	"errors"
+
+	"github.com/spacemeshos/go-spacemesh/merkle/pb"
+)
+
+// EmptyNodeHash is the well-known hash used for a nil branch child so that
+// two peers comparing trees agree on "this sub-tree has no value" without
+// having to special-case nil anywhere in the sync protocol.
+var EmptyNodeHash = []byte("")
+
+// PrefixNotFoundError is returned when a requested hex-encoded prefix does
+// not resolve to any node in the tree.
+var PrefixNotFoundError = errors.New("no node found for requested prefix")
+
+// NodeInfo is the wire representation of a single tree node as returned by
+// GetMerkleNode. A branch carries its children's hashes and, when a key's
+// whole path ends exactly at that branch (see upsert's "whole path matched"
+// case), its own value as well - HasValue distinguishes that from a branch
+// with no value of its own, since Value/LargeValue being unset looks the
+// same as them being empty.
+type NodeInfo struct {
+	Prefix     string     // hex-encoded path from root to this node
+	Children   [16][]byte // branch children hashes, nil entries are EmptyNodeHash
+	Leaf       bool
+	Key        string // full hex-encoded key, only set when Leaf is true
+	HasValue   bool   // set when a branch stores its own value directly, see above
+	Value      []byte // the tree-stored value, set when Leaf is true or HasValue is true - this is sha256(v) for large values, see LargeValue
+	LargeValue []byte // set when Value is a digest of a user value stored separately (see Put); carries the original value so a syncing peer can replicate it, the same way Proof.Value does for proofs
+}
+
+// RootHash returns the hash of the tree's root node, or EmptyNodeHash for an
+// empty tree.
+func (mt *merkleTreeImp) RootHash() ([]byte, error) {
+	if mt.root == nil {
+		return EmptyNodeHash, nil
+	}
+	return mt.root.getNodeHash()
+}
+
+// GetMerkleNode walks the tree following prefix (a hex-encoded nibble path
+// from the root) and returns the node found there. It is the server side of
+// the anti-entropy sync protocol's GetMerkleNode RPC: a peer that disagrees
+// with our root hash calls this repeatedly, descending one level at a time,
+// until it isolates the differing leaves.
+func (mt *merkleTreeImp) GetMerkleNode(prefix string) (*NodeInfo, error) {
+
+	n := mt.root
+	matched := 0
+
+	for matched < len(prefix) {
+		if n == nil {
+			return nil, PrefixNotFoundError
+		}
+
+		switch n.getNodeType() {
+		case pb.NodeType_branch:
+			idx := string(prefix[matched])
+			matched++
+			n = n.getBranchNode().getChild(idx)
+
+		case pb.NodeType_extension:
+			path := n.getShortNode().getPath()
+			if len(prefix[matched:]) < len(path) || commonPrefix(path, prefix[matched:]) != path {
+				return nil, PrefixNotFoundError
+			}
+			matched += len(path)
+			child, err := mt.getNode(n.getShortNode().getValue())
+			if err != nil {
+				return nil, err
+			}
+			n = child
+
+		case pb.NodeType_leaf:
+			path := n.getShortNode().getPath()
+			if prefix[matched:] != path {
+				return nil, PrefixNotFoundError
+			}
+			// the rest of prefix is the leaf's own path, which nodeInfo
+			// appends when it sets Key
+			return mt.nodeInfo(prefix[:matched], n)
+
+		default:
+			return nil, errors.New("unexpected node type")
+		}
+	}
+
+	if n == nil {
+		return nil, PrefixNotFoundError
+	}
+
+	return mt.nodeInfo(prefix, n)
+}
+
+// nodeInfo converts an in-memory node at prefix into its wire representation.
+func (mt *merkleTreeImp) nodeInfo(prefix string, n Node) (*NodeInfo, error) {
+	info := &NodeInfo{Prefix: prefix}
+
+	switch n.getNodeType() {
+	case pb.NodeType_leaf:
+		info.Leaf = true
+		info.Key = prefix + n.getShortNode().getPath()
+		stored := n.getShortNode().getValue()
+		info.Value = stored
+		if len(stored) == 32 {
+			if v, err := mt.getUserValue(stored); err == nil {
+				info.LargeValue = v
+			}
+		}
+		return info, nil
+
+	case pb.NodeType_branch:
+		b := n.getBranchNode()
+		if stored := b.getValue(); stored != nil {
+			info.HasValue = true
+			info.Value = stored
+			if len(stored) == 32 {
+				if v, err := mt.getUserValue(stored); err == nil {
+					info.LargeValue = v
+				}
+			}
+		}
+		for i := 0; i < 16; i++ {
+			child := b.getChild(string(hexChar(i)))
+			if child == nil {
+				info.Children[i] = EmptyNodeHash
+				continue
+			}
+			hash, err := child.getNodeHash()
+			if err != nil {
+				return nil, err
+			}
+			info.Children[i] = hash
+		}
+		return info, nil
+
+	default:
+		// extensions are transparent on the wire - resolve to the node they point
+		// to, extending prefix by the extension's own path so it still matches the
+		// real nibble path to the resolved node, mirroring VerifyProof's matched +=
+		// len(path) in merkle/proof.go
+		child, err := mt.getNode(n.getShortNode().getValue())
+		if err != nil {
+			return nil, err
+		}
+		return mt.nodeInfo(prefix+n.getShortNode().getPath(), child)
+	}
+}
+
+// hexChar returns the lower-case hex digit for i in [0,16).
+func hexChar(i int) byte {
+	const digits = "0123456789abcdef"
+	return digits[i]
+}
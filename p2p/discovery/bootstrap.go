@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ParseBootstrapNodes converts SwarmConfig.BootstrapNodes entries into seed
+// Records for Discovery.Start, so swarm code that currently reads
+// BootstrapNodes directly has a path onto the real discovery layer instead:
+// bootstrap off the parsed seeds for one Start call, then call RandomNodes
+// from then on. Each entry is "host:port/pubkey", where pubkey is the hex
+// encoding of the same uncompressed-point format Records carry on the wire
+// (see encodeRecord); host:port is used for both the seed's TCP and UDP
+// ports since a bootstrap entry only ever specifies one.
+func ParseBootstrapNodes(nodes []string) ([]*Record, error) {
+	seeds := make([]*Record, 0, len(nodes))
+	for _, n := range nodes {
+		rec, err := parseBootstrapNode(n)
+		if err != nil {
+			return nil, fmt.Errorf("discovery: invalid bootstrap node %q: %w", n, err)
+		}
+		seeds = append(seeds, rec)
+	}
+	return seeds, nil
+}
+
+func parseBootstrapNode(n string) (*Record, error) {
+	sep := strings.LastIndex(n, "/")
+	if sep < 0 {
+		return nil, fmt.Errorf("missing /pubkey suffix")
+	}
+	hostPort, pubHex := n[:sep], n[sep+1:]
+
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP %q", host)
+	}
+
+	pubBytes, err := hex.DecodeString(pubHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey encoding: %w", err)
+	}
+	x, y := elliptic.Unmarshal(curve, pubBytes)
+	if x == nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	return &Record{
+		IP:     ip,
+		TCP:    uint16(port),
+		UDP:    uint16(port),
+		PubKey: &ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+	}, nil
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+func bootstrapEntry(t *testing.T, addr string) string {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	pubHex := hex.EncodeToString(elliptic.Marshal(curve, priv.PublicKey.X, priv.PublicKey.Y))
+	return addr + "/" + pubHex
+}
+
+func TestParseBootstrapNodesReturnsUsableSeeds(t *testing.T) {
+	entries := []string{bootstrapEntry(t, "127.0.0.1:3572"), bootstrapEntry(t, "127.0.0.1:3763")}
+
+	seeds, err := ParseBootstrapNodes(entries)
+	if err != nil {
+		t.Fatalf("ParseBootstrapNodes failed: %v", err)
+	}
+	if len(seeds) != 2 {
+		t.Fatalf("expected 2 seeds, got %d", len(seeds))
+	}
+	for i, s := range seeds {
+		if s.PubKey == nil {
+			t.Fatalf("seed %d has no public key", i)
+		}
+		if s.TCP != 3572 && s.TCP != 3763 {
+			t.Fatalf("seed %d has unexpected port %d", i, s.TCP)
+		}
+	}
+}
+
+func TestParseBootstrapNodesRejectsMalformedEntry(t *testing.T) {
+	if _, err := ParseBootstrapNodes([]string{"not-a-valid-entry"}); err == nil {
+		t.Fatalf("expected an error for an entry with no /pubkey suffix")
+	}
+}
+
+func TestParseBootstrapNodesRejectsInvalidPubkey(t *testing.T) {
+	if _, err := ParseBootstrapNodes([]string{"127.0.0.1:3572/not-hex!!"}); err == nil {
+		t.Fatalf("expected an error for a non-hex pubkey")
+	}
+}
+
+// TestBootstrapSeedsTableFromConfig guards against Bootstrap silently not
+// wiring SwarmConfig.BootstrapNodes into Start's seeds.
+func TestBootstrapSeedsTableFromConfig(t *testing.T) {
+	self, _ := signedRecord(t, nil)
+	entry := bootstrapEntry(t, "127.0.0.1:3572")
+
+	d := New(self, nodeconfig.SwarmConfig{
+		RoutingTableBucketSize: 20,
+		RoutingTableAlpha:      3,
+		BootstrapNodes:         []string{entry},
+	})
+	defer d.Stop()
+
+	if err := d.Bootstrap(0); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	if got := len(d.RandomNodes(1)); got != 1 {
+		t.Fatalf("expected the parsed bootstrap node to be seeded into the table, got %d entries", got)
+	}
+}
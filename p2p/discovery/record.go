@@ -0,0 +1,249 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/spacemeshos/go-spacemesh/crypto"
+)
+
+// curve is the curve all discovery keys are expected to use, so records can
+// be marshaled to/from raw bytes on the wire without carrying curve info.
+var curve = elliptic.P256()
+
+// NodeID identifies a node in the routing table: sha256 of its public key,
+// so distance between nodes can be computed by XOR-ing two fixed-size IDs.
+type NodeID [32]byte
+
+// Record is a signed, self-describing description of how to reach a node,
+// modeled on Ethereum's ENR. Nodes gossip their own Record so peers always
+// have fresh connection info without a central directory.
+type Record struct {
+	IP     []byte // 4 or 16 bytes
+	TCP    uint16
+	UDP    uint16
+	PubKey *ecdsa.PublicKey
+	Seq    uint64            // increases whenever the node republishes, so stale records lose to fresher ones
+	Attrs  map[string]string // free-form, e.g. protocol version
+
+	sig []byte
+}
+
+// ErrInvalidSignature is returned by VerifyRecord when a record's signature
+// does not match its content and claimed public key.
+var ErrInvalidSignature = errors.New("discovery: record signature does not verify")
+
+// ID returns the node's routing table identity.
+func (r *Record) ID() NodeID {
+	return IDFromPubKey(r.PubKey)
+}
+
+// IDFromPubKey derives the routing table identity for a public key.
+func IDFromPubKey(pub *ecdsa.PublicKey) NodeID {
+	var id NodeID
+	copy(id[:], crypto.Sha256(append(pub.X.Bytes(), pub.Y.Bytes()...)))
+	return id
+}
+
+// encode returns the bytes of the record that are signed - everything
+// except PubKey and the signature itself (Verify checks the signature
+// against PubKey). Attrs are walked in sorted key order so that
+// two calls on the same Record always produce identical bytes - map
+// iteration order is randomized per range in Go, which would otherwise make
+// Sign/Verify flaky whenever Attrs has 2+ entries.
+func (r *Record) encode() []byte {
+	buf := make([]byte, 0, 32+len(r.IP)+len(r.Attrs)*8)
+	buf = append(buf, r.IP...)
+
+	var port [2]byte
+	binary.BigEndian.PutUint16(port[:], r.TCP)
+	buf = append(buf, port[:]...)
+	binary.BigEndian.PutUint16(port[:], r.UDP)
+	buf = append(buf, port[:]...)
+
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], r.Seq)
+	buf = append(buf, seq[:]...)
+
+	for _, k := range sortedAttrKeys(r.Attrs) {
+		buf = appendLenPrefixed(buf, []byte(k))
+		buf = appendLenPrefixed(buf, []byte(r.Attrs[k]))
+	}
+
+	return buf
+}
+
+// sortedAttrKeys returns attrs's keys in sorted order, so Attrs can be
+// walked deterministically both when signing/verifying a record (encode)
+// and when putting it on the wire (encodeRecord).
+func sortedAttrKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appendLenPrefixed appends b to buf preceded by its length as a uint16, so
+// consecutive variable-length fields (e.g. an Attrs key then its value)
+// decode unambiguously.
+func appendLenPrefixed(buf, b []byte) []byte {
+	buf = appendUint16(buf, uint16(len(b)))
+	return append(buf, b...)
+}
+
+// decodeLenPrefixed reads one appendLenPrefixed-encoded field off the front
+// of data, returning it along with the remaining bytes.
+func decodeLenPrefixed(data []byte) (field, rest []byte, err error) {
+	if len(data) < 2 {
+		return nil, nil, fmt.Errorf("discovery: truncated record")
+	}
+	l := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < l {
+		return nil, nil, fmt.Errorf("discovery: truncated record")
+	}
+	return data[:l], data[l:], nil
+}
+
+// Sign signs the record with priv, which must match PubKey.
+func (r *Record) Sign(priv *ecdsa.PrivateKey) error {
+	sig, err := crypto.SignEcdsa(priv, crypto.Sha256(r.encode()))
+	if err != nil {
+		return err
+	}
+	r.sig = sig
+	return nil
+}
+
+// Verify checks that the record's signature matches its content and
+// claimed public key.
+func (r *Record) Verify() error {
+	if r.PubKey == nil || r.sig == nil {
+		return ErrInvalidSignature
+	}
+	if !crypto.VerifyEcdsa(r.PubKey, crypto.Sha256(r.encode()), r.sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// encodeRecord serializes a record to its UDP wire form: ip, tcp/udp ports,
+// seq, Attrs, the marshaled public key and signature. Attrs must cross the
+// wire in the same sorted order encode() signs them in, or a record signed
+// with non-empty Attrs would always fail Verify() once decoded on the other
+// end.
+func encodeRecord(r *Record) ([]byte, error) {
+	if r.PubKey == nil {
+		return nil, fmt.Errorf("discovery: record has no public key")
+	}
+
+	pub := elliptic.Marshal(curve, r.PubKey.X, r.PubKey.Y)
+
+	buf := []byte{byte(len(r.IP))}
+	buf = append(buf, r.IP...)
+
+	var u16 [2]byte
+	binary.BigEndian.PutUint16(u16[:], r.TCP)
+	buf = append(buf, u16[:]...)
+	binary.BigEndian.PutUint16(u16[:], r.UDP)
+	buf = append(buf, u16[:]...)
+
+	var u64 [8]byte
+	binary.BigEndian.PutUint64(u64[:], r.Seq)
+	buf = append(buf, u64[:]...)
+
+	keys := sortedAttrKeys(r.Attrs)
+	if len(keys) > 0xff {
+		return nil, fmt.Errorf("discovery: record has too many attrs to encode")
+	}
+	buf = append(buf, byte(len(keys)))
+	for _, k := range keys {
+		buf = appendLenPrefixed(buf, []byte(k))
+		buf = appendLenPrefixed(buf, []byte(r.Attrs[k]))
+	}
+
+	binary.BigEndian.PutUint16(u16[:], uint16(len(pub)))
+	buf = append(buf, u16[:]...)
+	buf = append(buf, pub...)
+
+	binary.BigEndian.PutUint16(u16[:], uint16(len(r.sig)))
+	buf = append(buf, u16[:]...)
+	buf = append(buf, r.sig...)
+
+	return buf, nil
+}
+
+func decodeRecord(data []byte) (*Record, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	ipLen := int(data[0])
+	data = data[1:]
+
+	if len(data) < ipLen+12 {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	r := &Record{IP: append([]byte{}, data[:ipLen]...)}
+	data = data[ipLen:]
+
+	r.TCP = binary.BigEndian.Uint16(data)
+	data = data[2:]
+	r.UDP = binary.BigEndian.Uint16(data)
+	data = data[2:]
+	r.Seq = binary.BigEndian.Uint64(data)
+	data = data[8:]
+
+	if len(data) < 1 {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	attrCount := int(data[0])
+	data = data[1:]
+	if attrCount > 0 {
+		r.Attrs = make(map[string]string, attrCount)
+	}
+	for i := 0; i < attrCount; i++ {
+		k, rest, err := decodeLenPrefixed(data)
+		if err != nil {
+			return nil, err
+		}
+		v, rest, err := decodeLenPrefixed(rest)
+		if err != nil {
+			return nil, err
+		}
+		r.Attrs[string(k)] = string(v)
+		data = rest
+	}
+
+	if len(data) < 2 {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	pubLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < pubLen {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	x, y := elliptic.Unmarshal(curve, data[:pubLen])
+	if x == nil {
+		return nil, fmt.Errorf("discovery: invalid public key encoding")
+	}
+	r.PubKey = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	data = data[pubLen:]
+
+	if len(data) < 2 {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	sigLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < sigLen {
+		return nil, fmt.Errorf("discovery: truncated record")
+	}
+	r.sig = append([]byte{}, data[:sigLen]...)
+
+	return r, nil
+}
@@ -0,0 +1,74 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+)
+
+func signedRecord(t *testing.T, attrs map[string]string) (*Record, *ecdsa.PrivateKey) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	r := &Record{IP: []byte{127, 0, 0, 1}, TCP: 7513, UDP: 7513, PubKey: &priv.PublicKey, Attrs: attrs}
+	if err := r.Sign(priv); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	return r, priv
+}
+
+// TestEncodeIsDeterministic guards against encode() ranging over Attrs
+// directly: map iteration order is randomized per range, so two calls on
+// the very same Record must still agree on the signed bytes.
+func TestEncodeIsDeterministic(t *testing.T) {
+	r := &Record{
+		IP:    []byte{127, 0, 0, 1},
+		Attrs: map[string]string{"version": "1", "chain": "testnet", "a": "b"},
+	}
+	first := r.encode()
+	for i := 0; i < 10; i++ {
+		if string(r.encode()) != string(first) {
+			t.Fatalf("encode() is not deterministic across repeated calls")
+		}
+	}
+}
+
+func TestVerifyAcceptsOwnSignature(t *testing.T) {
+	r, _ := signedRecord(t, map[string]string{"version": "1", "chain": "testnet"})
+	if err := r.Verify(); err != nil {
+		t.Fatalf("expected Verify to succeed on a freshly signed record: %v", err)
+	}
+}
+
+// TestVerifySurvivesWireRoundTrip is the regression test for the bug where
+// a record signed with non-empty Attrs always failed Verify() once decoded,
+// because encodeRecord/decodeRecord dropped Attrs on the floor.
+func TestVerifySurvivesWireRoundTrip(t *testing.T) {
+	r, _ := signedRecord(t, map[string]string{"version": "1", "chain": "testnet"})
+
+	data, err := encodeRecord(r)
+	if err != nil {
+		t.Fatalf("encodeRecord failed: %v", err)
+	}
+	decoded, err := decodeRecord(data)
+	if err != nil {
+		t.Fatalf("decodeRecord failed: %v", err)
+	}
+
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("expected decoded record to verify, got: %v", err)
+	}
+	if decoded.Attrs["version"] != "1" || decoded.Attrs["chain"] != "testnet" {
+		t.Fatalf("expected Attrs to survive the wire round trip, got %v", decoded.Attrs)
+	}
+}
+
+func TestVerifyRejectsTamperedRecord(t *testing.T) {
+	r, _ := signedRecord(t, nil)
+	r.TCP++ // forge a different port after signing
+	if err := r.Verify(); err == nil {
+		t.Fatalf("expected Verify to reject a record tampered with after signing")
+	}
+}
@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// bucketCount is one bucket per bit of a NodeID.
+const bucketCount = len(NodeID{}) * 8
+
+// bucket holds up to config.RoutingTableBucketSize records, ordered
+// least-recently-seen first so refreshing a bucket always pings the
+// staleest entries.
+type bucket struct {
+	records []*Record
+}
+
+// table is a Kademlia routing table keyed by XOR distance from self, sized
+// and fanned out per SwarmConfig.RoutingTableBucketSize /
+// SwarmConfig.RoutingTableAlpha.
+type table struct {
+	mu      sync.RWMutex
+	self    NodeID
+	buckets [bucketCount]*bucket
+	config  nodeconfig.SwarmConfig
+}
+
+func newTable(self NodeID, config nodeconfig.SwarmConfig) *table {
+	t := &table{self: self, config: config}
+	for i := range t.buckets {
+		t.buckets[i] = &bucket{}
+	}
+	return t
+}
+
+// distance returns the index (0 = closest) of the bucket other falls into
+// relative to self, i.e. the position of the highest differing bit, counted
+// from the least significant bit.
+func distance(self, other NodeID) int {
+	for i := 0; i < len(self); i++ {
+		x := self[i] ^ other[i]
+		if x == 0 {
+			continue
+		}
+		for b := 0; b < 8; b++ {
+			if x&(0x80>>uint(b)) != 0 {
+				return bucketCount - 1 - (i*8 + b)
+			}
+		}
+	}
+	return 0 // self
+}
+
+// Add inserts or refreshes rec in the table, evicting the least-recently-seen
+// entry from its bucket if the bucket is already at
+// RoutingTableBucketSize capacity.
+func (t *table) Add(rec *Record) {
+	id := rec.ID()
+	if id == t.self {
+		return
+	}
+
+	b := t.buckets[distance(t.self, id)]
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, r := range b.records {
+		if r.ID() == id {
+			// move to the back - most-recently-seen
+			b.records = append(append(b.records[:i], b.records[i+1:]...), rec)
+			return
+		}
+	}
+
+	if len(b.records) >= t.config.RoutingTableBucketSize {
+		b.records = b.records[1:] // evict least-recently-seen
+	}
+	b.records = append(b.records, rec)
+}
+
+// Closest returns the up-to-n records closest to target, across all
+// buckets, sorted nearest-first.
+func (t *table) Closest(target NodeID, n int) []*Record {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var all []*Record
+	for _, b := range t.buckets {
+		all = append(all, b.records...)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return distance(target, all[i].ID()) < distance(target, all[j].ID())
+	})
+
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// Random returns up to n records picked uniformly at random from across the
+// table, used to seed outbound dials when any peer will do. Shuffling
+// matters here: without it, callers would keep drawing the same
+// high-index-bucket-biased tail of the table and never get a diverse peer
+// set.
+func (t *table) Random(n int) []*Record {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var all []*Record
+	for _, b := range t.buckets {
+		all = append(all, b.records...)
+	}
+
+	rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// leastRecentlySeen returns the bucket with index i's single oldest record,
+// used by the periodic bucket-refresh timer, or nil if the bucket is empty.
+func (t *table) leastRecentlySeen(i int) *Record {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	b := t.buckets[i]
+	if len(b.records) == 0 {
+		return nil
+	}
+	return b.records[0]
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+func testConfig() nodeconfig.SwarmConfig {
+	return nodeconfig.SwarmConfig{
+		RoutingTableBucketSize: 20,
+		RoutingTableAlpha:      3,
+	}
+}
+
+func randomRecord(t *testing.T) *Record {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &Record{IP: []byte{127, 0, 0, 1}, TCP: 7513, UDP: 7513, PubKey: &priv.PublicKey}
+}
+
+func TestTableAddAndClosest(t *testing.T) {
+	self := randomRecord(t)
+	tb := newTable(self.ID(), testConfig())
+
+	var recs []*Record
+	for i := 0; i < 30; i++ {
+		r := randomRecord(t)
+		recs = append(recs, r)
+		tb.Add(r)
+	}
+
+	closest := tb.Closest(recs[0].ID(), 5)
+	if len(closest) != 5 {
+		t.Fatalf("expected 5 closest records, got %d", len(closest))
+	}
+	// recs[0] itself must be the closest match to its own id.
+	if closest[0].ID() != recs[0].ID() {
+		t.Fatalf("expected recs[0] to be its own closest match")
+	}
+}
+
+func TestTableRandomIsShuffled(t *testing.T) {
+	self := randomRecord(t)
+	tb := newTable(self.ID(), testConfig())
+
+	for i := 0; i < 50; i++ {
+		tb.Add(randomRecord(t))
+	}
+
+	first := tb.Random(50)
+	second := tb.Random(50)
+
+	if len(first) != 50 || len(second) != 50 {
+		t.Fatalf("expected 50 records back, got %d and %d", len(first), len(second))
+	}
+
+	same := true
+	for i := range first {
+		if first[i].ID() != second[i].ID() {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Fatalf("two consecutive Random() calls returned the same order - not actually randomized")
+	}
+}
+
+func TestDistanceIsSymmetric(t *testing.T) {
+	a := NodeID{}
+	b := NodeID{}
+	b[0] = 0x01
+
+	if distance(a, b) != distance(b, a) {
+		t.Fatalf("distance should be symmetric")
+	}
+}
@@ -0,0 +1,378 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// messageKind identifies the four discv5-style UDP messages this protocol
+// speaks.
+type messageKind byte
+
+const (
+	kindPing messageKind = iota
+	kindPong
+	kindFindNode
+	kindNodes
+)
+
+type message struct {
+	kind   messageKind
+	target NodeID  // set on findNode
+	record *Record // set on ping/pong, the sender's own record
+	nodes  []*Record
+}
+
+// refreshInterval is how often refreshLoop pings the least-recently-seen
+// record of every non-empty bucket.
+const refreshInterval = 10 * time.Minute
+
+// findNodeTimeout bounds how long findNode waits for a NODES reply before
+// giving up on that peer.
+const findNodeTimeout = 2 * time.Second
+
+// Discovery runs the UDP PING/PONG/FINDNODE/NODES protocol and maintains a
+// Kademlia routing table of other nodes' Records, keyed by
+// sha256(pubkey) XOR distance.
+type Discovery struct {
+	self  *Record
+	table *table
+	conn  *net.UDPConn
+	quit  chan struct{}
+
+	pendingMu sync.Mutex
+	pending   map[string]chan []*Record // addr.String() -> channel awaiting a NODES reply
+}
+
+// New creates a Discovery instance bound to self's own record and sized per
+// config's RoutingTableBucketSize/RoutingTableAlpha.
+func New(self *Record, config nodeconfig.SwarmConfig) *Discovery {
+	return &Discovery{
+		self:    self,
+		table:   newTable(self.ID(), config),
+		quit:    make(chan struct{}),
+		pending: make(map[string]chan []*Record),
+	}
+}
+
+// Start opens the UDP socket on port, contacts seeds, performs a
+// self-lookup to fill the routing table, and then refreshes
+// least-recently-seen buckets on a timer until Stop is called.
+func (d *Discovery) Start(port int, seeds []*Record) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+
+	for _, seed := range seeds {
+		d.table.Add(seed)
+	}
+
+	go d.readLoop()
+	go d.refreshLoop()
+
+	d.lookup(d.self.ID())
+
+	return nil
+}
+
+// Stop closes the UDP socket and stops the refresh timer.
+func (d *Discovery) Stop() error {
+	close(d.quit)
+	return d.conn.Close()
+}
+
+// RandomNodes returns up to n records this node currently knows about,
+// suitable for a caller that needs peers to dial. Replaces reading
+// SwarmConfig.BootstrapNodes directly once discovery has warmed up.
+func (d *Discovery) RandomNodes(n int) []*Record {
+	return d.table.Random(n)
+}
+
+// Bootstrap is the config-driven entry point for swarm code that used to
+// read SwarmConfig.BootstrapNodes directly: it parses those entries via
+// ParseBootstrapNodes and calls Start with the resulting seeds. Once
+// Bootstrap returns, callers should get their peers from RandomNodes
+// instead of reading BootstrapNodes again.
+func (d *Discovery) Bootstrap(port int) error {
+	seeds, err := ParseBootstrapNodes(d.table.config.BootstrapNodes)
+	if err != nil {
+		return err
+	}
+	return d.Start(port, seeds)
+}
+
+func (d *Discovery) refreshLoop() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for i := 0; i < bucketCount; i++ {
+				if rec := d.table.leastRecentlySeen(i); rec != nil {
+					d.ping(rec)
+				}
+			}
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// lookup performs an iterative FINDNODE search for target, querying up to
+// RoutingTableAlpha frontier nodes concurrently per round (the standard
+// Kademlia alpha fan-out), and adding every record it discovers to the
+// routing table along the way. This is how a freshly started node fills
+// its buckets from just the seed nodes.
+func (d *Discovery) lookup(target NodeID) {
+	queried := make(map[NodeID]bool)
+	alpha := d.table.config.RoutingTableAlpha
+	if alpha < 1 {
+		alpha = 1
+	}
+
+	frontier := d.table.Closest(target, 16)
+	for len(frontier) > 0 {
+		batchSize := alpha
+		if batchSize > len(frontier) {
+			batchSize = len(frontier)
+		}
+		batch := frontier[:batchSize]
+		frontier = frontier[batchSize:]
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		var found []*Record
+		for _, next := range batch {
+			if queried[next.ID()] {
+				continue
+			}
+			queried[next.ID()] = true
+
+			wg.Add(1)
+			go func(next *Record) {
+				defer wg.Done()
+				reply := d.findNode(next, target)
+				mu.Lock()
+				found = append(found, reply...)
+				mu.Unlock()
+			}(next)
+		}
+		wg.Wait()
+
+		for _, rec := range found {
+			if err := rec.Verify(); err != nil {
+				log.Error("discovery: dropping record with invalid signature from NODES reply: %v", err)
+				continue
+			}
+			d.table.Add(rec)
+			if !queried[rec.ID()] {
+				frontier = append(frontier, rec)
+			}
+		}
+	}
+}
+
+func (d *Discovery) readLoop() {
+	buf := make([]byte, 1280)
+	for {
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-d.quit:
+				return
+			default:
+				log.Error("discovery: udp read failed: %v", err)
+				continue
+			}
+		}
+		d.handle(buf[:n], addr)
+	}
+}
+
+func (d *Discovery) handle(data []byte, addr net.Addr) {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		log.Error("discovery: dropping malformed message from %v: %v", addr, err)
+		return
+	}
+
+	switch msg.kind {
+	case kindPing:
+		if msg.record != nil {
+			d.addIfVerified(msg.record, addr)
+		}
+		d.send(addr, message{kind: kindPong, record: d.self})
+
+	case kindPong:
+		if msg.record != nil {
+			d.addIfVerified(msg.record, addr)
+		}
+
+	case kindFindNode:
+		closest := d.table.Closest(msg.target, d.table.config.RoutingTableBucketSize)
+		d.send(addr, message{kind: kindNodes, nodes: closest})
+
+	case kindNodes:
+		d.pendingMu.Lock()
+		reply, ok := d.pending[addr.String()]
+		if ok {
+			delete(d.pending, addr.String())
+		}
+		d.pendingMu.Unlock()
+		if ok {
+			reply <- msg.nodes
+		}
+	}
+}
+
+// addIfVerified adds rec to the routing table only if its signature checks
+// out, so a peer can't hand out a record with a forged IP/port or pubkey
+// and have it accepted into the table. addr is only used for the log line.
+func (d *Discovery) addIfVerified(rec *Record, addr net.Addr) {
+	if err := rec.Verify(); err != nil {
+		log.Error("discovery: dropping record with invalid signature from %v: %v", addr, err)
+		return
+	}
+	d.table.Add(rec)
+}
+
+// ping sends a PING to rec. ping itself does not touch the table; if rec
+// answers, handle adds its PONG record back as most-recently-seen.
+func (d *Discovery) ping(rec *Record) {
+	d.send(udpAddr(rec), message{kind: kindPing, record: d.self})
+}
+
+// findNode sends a FINDNODE for target to rec and waits for its NODES
+// reply, correlated by rec's address, up to findNodeTimeout. Returns nil if
+// rec never replies in time.
+func (d *Discovery) findNode(rec *Record, target NodeID) []*Record {
+	addr := udpAddr(rec)
+	key := addr.String()
+
+	reply := make(chan []*Record, 1)
+	d.pendingMu.Lock()
+	d.pending[key] = reply
+	d.pendingMu.Unlock()
+
+	d.send(addr, message{kind: kindFindNode, target: target})
+
+	select {
+	case nodes := <-reply:
+		return nodes
+	case <-time.After(findNodeTimeout):
+		d.pendingMu.Lock()
+		delete(d.pending, key)
+		d.pendingMu.Unlock()
+		return nil
+	}
+}
+
+func (d *Discovery) send(addr net.Addr, msg message) {
+	data, err := encodeMessage(msg)
+	if err != nil {
+		log.Error("discovery: failed to encode message: %v", err)
+		return
+	}
+	if _, err := d.conn.WriteTo(data, addr); err != nil {
+		log.Error("discovery: failed to send message to %v: %v", addr, err)
+	}
+}
+
+func udpAddr(rec *Record) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IP(rec.IP), Port: int(rec.UDP)}
+}
+
+// encodeMessage serializes msg to its UDP wire form, via encodeRecord for
+// any Records it carries.
+func encodeMessage(msg message) ([]byte, error) {
+	buf := []byte{byte(msg.kind)}
+
+	switch msg.kind {
+	case kindPing, kindPong:
+		rec, err := encodeRecord(msg.record)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, rec...)
+
+	case kindFindNode:
+		buf = append(buf, msg.target[:]...)
+
+	case kindNodes:
+		buf = append(buf, byte(len(msg.nodes)))
+		for _, rec := range msg.nodes {
+			encoded, err := encodeRecord(rec)
+			if err != nil {
+				return nil, err
+			}
+			buf = appendUint16(buf, uint16(len(encoded)))
+			buf = append(buf, encoded...)
+		}
+	}
+
+	return buf, nil
+}
+
+func decodeMessage(data []byte) (*message, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("discovery: empty message")
+	}
+
+	msg := &message{kind: messageKind(data[0])}
+	rest := data[1:]
+
+	switch msg.kind {
+	case kindPing, kindPong:
+		rec, err := decodeRecord(rest)
+		if err != nil {
+			return nil, err
+		}
+		msg.record = rec
+
+	case kindFindNode:
+		if len(rest) < len(NodeID{}) {
+			return nil, fmt.Errorf("discovery: findNode message too short")
+		}
+		copy(msg.target[:], rest)
+
+	case kindNodes:
+		if len(rest) < 1 {
+			return nil, fmt.Errorf("discovery: nodes message too short")
+		}
+		count := int(rest[0])
+		rest = rest[1:]
+		for i := 0; i < count; i++ {
+			if len(rest) < 2 {
+				return nil, fmt.Errorf("discovery: truncated nodes message")
+			}
+			l := int(rest[0])<<8 | int(rest[1])
+			rest = rest[2:]
+			if len(rest) < l {
+				return nil, fmt.Errorf("discovery: truncated node record")
+			}
+			rec, err := decodeRecord(rest[:l])
+			if err != nil {
+				return nil, err
+			}
+			msg.nodes = append(msg.nodes, rec)
+			rest = rest[l:]
+		}
+
+	default:
+		return nil, fmt.Errorf("discovery: unknown message kind %d", msg.kind)
+	}
+
+	return msg, nil
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
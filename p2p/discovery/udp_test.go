@@ -0,0 +1,131 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// TestAddIfVerifiedRejectsUnsignedRecord guards against the bug where
+// handle() added PING/PONG records to the routing table without checking
+// their signature, letting a peer hand out a forged identity/address.
+func TestAddIfVerifiedRejectsUnsignedRecord(t *testing.T) {
+	self, _ := signedRecord(t, nil)
+	d := New(self, nodeconfig.SwarmConfig{RoutingTableBucketSize: 20, RoutingTableAlpha: 3})
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+
+	forged := randomRecord(t) // never signed
+
+	d.addIfVerified(forged, addr)
+
+	closest := d.table.Closest(forged.ID(), 1)
+	if len(closest) != 0 {
+		t.Fatalf("expected unsigned record to be rejected, but it was added to the table")
+	}
+}
+
+func TestAddIfVerifiedAcceptsSignedRecord(t *testing.T) {
+	self, _ := signedRecord(t, nil)
+	d := New(self, nodeconfig.SwarmConfig{RoutingTableBucketSize: 20, RoutingTableAlpha: 3})
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+
+	rec, _ := signedRecord(t, nil)
+
+	d.addIfVerified(rec, addr)
+
+	closest := d.table.Closest(rec.ID(), 1)
+	if len(closest) != 1 || closest[0].ID() != rec.ID() {
+		t.Fatalf("expected signed record to be added to the table")
+	}
+}
+
+// TestFindNodeReplyCapIsBucketSizeNotAlpha guards against the bug where a
+// NODES reply was capped by RoutingTableAlpha (the lookup fan-out
+// parameter, default 3) instead of RoutingTableBucketSize (the
+// reply/bucket capacity parameter, default 20), which silently shrank
+// every NODES reply down to 3 candidates regardless of config.
+func TestFindNodeReplyCapIsBucketSizeNotAlpha(t *testing.T) {
+	self, _ := signedRecord(t, nil)
+	d := New(self, nodeconfig.SwarmConfig{RoutingTableBucketSize: 20, RoutingTableAlpha: 3})
+	if err := d.Start(0, nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer d.Stop()
+
+	const seeded = 5 // more than RoutingTableAlpha, fewer than RoutingTableBucketSize
+	for i := 0; i < seeded; i++ {
+		d.table.Add(randomRecord(t))
+	}
+
+	requester, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to open requester socket: %v", err)
+	}
+	defer requester.Close()
+
+	data, err := encodeMessage(message{kind: kindFindNode, target: NodeID{}})
+	if err != nil {
+		t.Fatalf("failed to encode FINDNODE: %v", err)
+	}
+	d.handle(data, requester.LocalAddr())
+
+	buf := make([]byte, 4096)
+	requester.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := requester.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read NODES reply: %v", err)
+	}
+	msg, err := decodeMessage(buf[:n])
+	if err != nil {
+		t.Fatalf("failed to decode NODES reply: %v", err)
+	}
+	if len(msg.nodes) != seeded {
+		t.Fatalf("expected all %d seeded records in the reply, got %d", seeded, len(msg.nodes))
+	}
+}
+
+// TestLookupQueriesAlphaNodesConcurrently guards against the bug where
+// lookup queried its frontier strictly one node at a time instead of
+// fanning out RoutingTableAlpha requests concurrently, which made an
+// iterative lookup fully serial.
+func TestLookupQueriesAlphaNodesConcurrently(t *testing.T) {
+	self, _ := signedRecord(t, nil)
+	d := New(self, nodeconfig.SwarmConfig{RoutingTableBucketSize: 20, RoutingTableAlpha: 3})
+	if err := d.Start(0, nil); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer d.Stop()
+
+	// Seed 6 records pointing at addresses nothing listens on, so every
+	// findNode call in the lookup times out. With RoutingTableAlpha=3
+	// fan-out, 6 never-responding peers should time out in two rounds
+	// (~2*findNodeTimeout), not six serial rounds (~6*findNodeTimeout).
+	for i := 0; i < 6; i++ {
+		rec := randomRecord(t)
+		rec.IP = []byte{127, 0, 0, 1}
+		rec.UDP = unusedUDPPort(t)
+		d.table.Add(rec)
+	}
+
+	start := time.Now()
+	d.lookup(NodeID{})
+	elapsed := time.Since(start)
+
+	if elapsed > 3*findNodeTimeout {
+		t.Fatalf("lookup took %v, expected alpha fan-out to finish within ~2 timeouts, not up to 6 serial ones", elapsed)
+	}
+}
+
+// unusedUDPPort returns a UDP port nothing is listening on by briefly
+// binding to port 0 and releasing it.
+func unusedUDPPort(t *testing.T) uint16 {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to find an unused port: %v", err)
+	}
+	defer conn.Close()
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+}
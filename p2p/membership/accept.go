@@ -0,0 +1,65 @@
+package membership
+
+import (
+	"net"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+)
+
+// Listener is the subset of net.Listener the accept loop needs.
+type Listener interface {
+	Accept() (net.Conn, error)
+}
+
+// Handshake validates a freshly accepted connection and returns the
+// member's ID and dial-back address, or an error if the connection should
+// be dropped.
+type Handshake func(conn net.Conn) (id, addr string, err error)
+
+// AcceptLoop runs ln.Accept in a loop until Stop is called, registering
+// each successfully handshaken connection as a Member. Inbound handshakes
+// are capped by the AdmissionController so a burst of connection attempts
+// can't run an unbounded number of handshakes concurrently, and a listener
+// that starts erroring (e.g. the process is out of file descriptors) is
+// backed off instead of spinning the CPU in a tight retry loop.
+func (m *Membership) AcceptLoop(ln Listener, handshake Handshake) {
+	for {
+		select {
+		case <-m.quit:
+			return
+		default:
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-m.quit:
+				return
+			default:
+			}
+			log.Error("membership: accept failed, backing off: %v", err)
+			time.Sleep(m.accept.BackoffOnAcceptError())
+			continue
+		}
+		m.accept.ResetBackoff()
+
+		if !m.accept.TryAdmit() {
+			conn.Close()
+			continue
+		}
+		go m.handleAccepted(conn, handshake)
+	}
+}
+
+func (m *Membership) handleAccepted(conn net.Conn, handshake Handshake) {
+	defer m.accept.Release()
+	defer conn.Close()
+
+	id, addr, err := handshake(conn)
+	if err != nil {
+		log.Error("membership: handshake failed for %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+	m.Add(id, addr)
+}
@@ -0,0 +1,117 @@
+package membership
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// fakeConn is the minimal net.Conn needed to drive a handshake in tests.
+type fakeConn struct {
+	net.Conn
+	closed bool
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+
+// fakeListener hands back a fixed sequence of connections, then blocks once
+// exhausted until done is closed, after which it returns errNoMoreConns.
+type fakeListener struct {
+	conns []*fakeConn
+	i     int
+	done  chan struct{}
+}
+
+var errNoMoreConns = errors.New("no more connections")
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	if l.i >= len(l.conns) {
+		<-l.done // block forever once the scripted conns are exhausted
+		return nil, errNoMoreConns
+	}
+	c := l.conns[l.i]
+	l.i++
+	return c, nil
+}
+
+func TestAcceptLoopAdmitsAndRegistersMember(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	ln := &fakeListener{conns: []*fakeConn{{}}, done: make(chan struct{})}
+
+	handshakeDone := make(chan struct{})
+	handshake := func(conn net.Conn) (string, string, error) {
+		defer close(handshakeDone)
+		return "peer", "peer-addr", nil
+	}
+
+	go m.AcceptLoop(ln, handshake)
+	defer m.Stop()
+	defer close(ln.done)
+
+	select {
+	case <-handshakeDone:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for handshake to run")
+	}
+
+	// handshakeDone closes when the handshake callback returns, but
+	// handleAccepted only calls Add after that - poll briefly.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.RLock()
+		_, ok := m.members["peer"]
+		m.mu.RUnlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected handshake to register peer as a member")
+}
+
+func TestAcceptLoopRejectsBeyondAdmissionCap(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.accept = NewAdmissionController(1)
+
+	blockHandshake := make(chan struct{})
+	conns := []*fakeConn{{}, {}}
+	ln := &fakeListener{conns: conns, done: make(chan struct{})}
+
+	admitted := make(chan string, 2)
+	handshake := func(conn net.Conn) (string, string, error) {
+		admitted <- "admitted"
+		<-blockHandshake
+		return "peer", "peer-addr", nil
+	}
+
+	go m.AcceptLoop(ln, handshake)
+	defer m.Stop()
+	defer close(ln.done)
+	defer close(blockHandshake)
+
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for first handshake to start")
+	}
+
+	// The second connection should be rejected (closed) immediately since
+	// the admission cap of 1 is already held by the first, still-blocked
+	// handshake.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if conns[1].closed {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected second connection to be closed due to the admission cap")
+}
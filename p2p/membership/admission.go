@@ -0,0 +1,81 @@
+package membership
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConcurrentHandshakes = 64
+	defaultBackoffBase             = 50 * time.Millisecond
+	defaultBackoffMax              = 5 * time.Second
+)
+
+// AdmissionController caps the number of concurrent bounded-fan-out
+// operations a node runs at once - inbound handshakes on the accept loop, or
+// a SWIM indirect probe's fan-out to helper peers - and applies exponential
+// back-off when Accept on the listener itself starts erroring in a tight
+// loop (e.g. the process is out of file descriptors) so a bad patch doesn't
+// spin the CPU.
+type AdmissionController struct {
+	mu       sync.Mutex
+	inFlight int
+	max      int
+
+	consecutiveErrors int
+}
+
+// NewAdmissionController creates a controller allowing up to max concurrent
+// admissions. max <= 0 uses the package default.
+func NewAdmissionController(max int) *AdmissionController {
+	if max <= 0 {
+		max = defaultMaxConcurrentHandshakes
+	}
+	return &AdmissionController{max: max}
+}
+
+// TryAdmit reserves a handshake slot, returning false if the concurrent
+// handshake cap is already reached. Call Release when the handshake (accept
+// or reject) completes.
+func (a *AdmissionController) TryAdmit() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.inFlight >= a.max {
+		return false
+	}
+	a.inFlight++
+	return true
+}
+
+// Release frees a handshake slot reserved by TryAdmit.
+func (a *AdmissionController) Release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.inFlight > 0 {
+		a.inFlight--
+	}
+}
+
+// BackoffOnAcceptError records a listener.Accept error and returns how long
+// the accept loop should sleep before retrying, doubling each consecutive
+// failure up to defaultBackoffMax.
+func (a *AdmissionController) BackoffOnAcceptError() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.consecutiveErrors++
+	backoff := defaultBackoffBase << uint(a.consecutiveErrors-1)
+	if backoff > defaultBackoffMax || backoff <= 0 {
+		backoff = defaultBackoffMax
+	}
+	return backoff
+}
+
+// ResetBackoff clears the consecutive-error count once the accept loop
+// succeeds again.
+func (a *AdmissionController) ResetBackoff() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.consecutiveErrors = 0
+}
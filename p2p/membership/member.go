@@ -0,0 +1,54 @@
+// Package membership implements SWIM-style failure detection for the
+// swarm: each node periodically pings a random peer, escalates a missed
+// ping to an indirect probe through other members, and only declares a
+// peer dead after that also times out. Membership updates piggyback on
+// ping/ack payloads so the whole swarm converges without a coordinator.
+package membership
+
+import "time"
+
+// State is a member's failure-detector state, in the order SWIM escalates
+// through: alive -> suspect -> dead.
+type State int
+
+const (
+	Alive State = iota
+	Suspect
+	Dead
+)
+
+func (s State) String() string {
+	switch s {
+	case Alive:
+		return "alive"
+	case Suspect:
+		return "suspect"
+	case Dead:
+		return "dead"
+	default:
+		return "unknown"
+	}
+}
+
+// Member is this node's view of one other member of the swarm.
+type Member struct {
+	ID    string
+	Addr  string
+	State State
+
+	// Incarnation lets a member refute stale suspect/dead gossip about
+	// itself: it bumps its own incarnation and rebroadcasts Alive, and any
+	// update with a lower incarnation than what's already known is ignored.
+	Incarnation uint64
+
+	LastSeen  time.Time // last time we got a direct or indirect ack from this member
+	DeadSince time.Time // set when State transitions to Dead
+}
+
+// Update is a compact membership change piggybacked on a ping/ack payload.
+type Update struct {
+	ID          string
+	Addr        string
+	State       State
+	Incarnation uint64
+}
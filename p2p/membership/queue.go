@@ -0,0 +1,47 @@
+package membership
+
+import "sync"
+
+// OutboundQueue is a FIFO of a peer's pending piggyback updates. It used to
+// be a two-tier priority queue reserving a PriorityAlive tier for SWIM
+// gossip over a PriorityBulk tier for application traffic, but nothing in
+// the tree ever queued bulk traffic through it - Membership only ever
+// pushes its own piggyback updates, which don't need prioritizing against
+// each other - so the dead tier was dropped in favor of a plain queue.
+type OutboundQueue struct {
+	mu      sync.Mutex
+	pending [][]byte
+}
+
+// NewOutboundQueue creates an empty queue.
+func NewOutboundQueue() *OutboundQueue {
+	return &OutboundQueue{}
+}
+
+// Push enqueues msg.
+func (q *OutboundQueue) Push(msg []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, msg)
+}
+
+// Pop removes and returns the next message to send. Returns false if the
+// queue is empty.
+func (q *OutboundQueue) Pop() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, false
+	}
+	msg := q.pending[0]
+	q.pending = q.pending[1:]
+	return msg, true
+}
+
+// Len returns the number of queued messages.
+func (q *OutboundQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
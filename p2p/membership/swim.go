@@ -0,0 +1,356 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// Transport is what the membership layer needs from the swarm to run the
+// SWIM protocol: direct pings/acks to a specific member, carrying
+// piggybacked updates both ways.
+type Transport interface {
+	// Ping sends a direct ping to addr carrying updates, and returns the
+	// peer's own piggybacked updates if it acked within timeout.
+	Ping(addr string, updates []Update, timeout time.Duration) ([]Update, bool)
+
+	// IndirectPing asks via to ping target on our behalf, returning whether
+	// via reported a successful ack.
+	IndirectPing(via, target string, updates []Update, timeout time.Duration) bool
+}
+
+const (
+	defaultProbeInterval   = 1 * time.Second
+	defaultProbeTimeout    = 500 * time.Millisecond
+	defaultIndirectTimeout = 1 * time.Second
+	defaultSuspectTimeout  = 5 * time.Second
+	defaultIndirectPeers   = 3
+)
+
+// Membership runs the SWIM failure detector for one node and maintains its
+// view of the swarm.
+type Membership struct {
+	mu      sync.RWMutex
+	members map[string]*Member
+	self    Member
+
+	transport Transport
+	config    nodeconfig.SwarmConfig
+
+	outq      *OutboundQueue       // updates still to be piggybacked on outgoing pings
+	admission *AdmissionController // caps concurrent indirect probes in flight
+	accept    *AdmissionController // caps concurrent inbound handshakes, sized independently of admission
+	quit      chan struct{}
+}
+
+// New creates a Membership for self, which will probe peers it is told
+// about via Add.
+func New(self Member, transport Transport, config nodeconfig.SwarmConfig) *Membership {
+	self.State = Alive
+	self.LastSeen = time.Now()
+	return &Membership{
+		members:   make(map[string]*Member),
+		self:      self,
+		transport: transport,
+		config:    config,
+		outq:      NewOutboundQueue(),
+		admission: NewAdmissionController(config.RandomConnections),
+		accept:    NewAdmissionController(defaultMaxConcurrentHandshakes),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Add registers a peer to be probed. Newly added members start Alive. If id
+// is already known but has been Dead for longer than
+// SwarmConfig.DeadNodeReclaimAfter, the entry is overwritten so a new node
+// can reuse a reclaimed ID instead of being dropped forever.
+func (m *Membership) Add(id, addr string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mem, ok := m.members[id]; ok {
+		if mem.State != Dead || time.Since(mem.DeadSince) < m.config.DeadNodeReclaimAfter {
+			return
+		}
+	}
+	m.members[id] = &Member{ID: id, Addr: addr, State: Alive, LastSeen: time.Now()}
+}
+
+// Members returns a snapshot of this node's current view of the swarm, for
+// consumption by the merkle sync worker or any other subsystem that needs
+// a peer list.
+func (m *Membership) Members() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Member, 0, len(m.members))
+	for _, mem := range m.members {
+		out = append(out, *mem)
+	}
+	return out
+}
+
+// Start launches the probe loop. It returns immediately; call Stop to shut
+// it down.
+func (m *Membership) Start() {
+	go m.probeLoop()
+}
+
+// Stop terminates the probe loop.
+func (m *Membership) Stop() {
+	close(m.quit)
+}
+
+func (m *Membership) probeLoop() {
+	ticker := time.NewTicker(defaultProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.probeRandomMember()
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// probeRandomMember runs one SWIM round: ping a random member, escalate to
+// an indirect probe on timeout, and mark the member suspect or dead as the
+// escalation plays out.
+func (m *Membership) probeRandomMember() {
+	target := m.randomMember()
+	if target == nil {
+		return
+	}
+
+	updates := m.drainPending()
+
+	acked, gotUpdates := m.ping(target.Addr, updates)
+	if acked {
+		m.applyUpdates(gotUpdates)
+		m.markAlive(target.ID)
+		return
+	}
+
+	if m.indirectProbe(target, updates) {
+		m.markAlive(target.ID)
+		return
+	}
+
+	m.markSuspect(target)
+}
+
+func (m *Membership) ping(addr string, updates []Update) ([]Update, bool) {
+	return m.transport.Ping(addr, updates, defaultProbeTimeout)
+}
+
+// indirectProbe asks up to indirectPeerCount() other members
+// (RandomConnections, or defaultIndirectPeers when that is unset) to ping
+// target on our behalf, per SWIM's indirect-probe step - this is what lets SWIM
+// tell "my direct link to target is down" apart from "target is down". Each
+// helper ping is gated by the AdmissionController so a node with a huge
+// membership view can't fan out an unbounded number of concurrent probes.
+func (m *Membership) indirectProbe(target *Member, updates []Update) bool {
+	helpers := m.randomMembersExcept(target.ID, m.indirectPeerCount())
+
+	results := make(chan bool, len(helpers))
+	admitted := 0
+	for _, helper := range helpers {
+		if !m.admission.TryAdmit() {
+			continue
+		}
+		admitted++
+		h := helper
+		go func() {
+			defer m.admission.Release()
+			results <- m.transport.IndirectPing(h.Addr, target.Addr, updates, defaultIndirectTimeout)
+		}()
+	}
+
+	for i := 0; i < admitted; i++ {
+		if <-results {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Membership) indirectPeerCount() int {
+	if m.config.RandomConnections > 0 {
+		return m.config.RandomConnections
+	}
+	return defaultIndirectPeers
+}
+
+func (m *Membership) markAlive(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mem, ok := m.members[id]
+	if !ok {
+		return
+	}
+	mem.State = Alive
+	mem.LastSeen = time.Now()
+	m.queueUpdate(Update{ID: mem.ID, Addr: mem.Addr, State: Alive, Incarnation: mem.Incarnation})
+}
+
+func (m *Membership) markSuspect(target *Member) {
+	m.mu.Lock()
+	mem, ok := m.members[target.ID]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	if mem.State == Alive {
+		mem.State = Suspect
+		log.Info("membership: marking %s suspect", mem.ID)
+		m.queueUpdate(Update{ID: mem.ID, Addr: mem.Addr, State: Suspect, Incarnation: mem.Incarnation})
+	}
+	m.mu.Unlock()
+
+	time.AfterFunc(defaultSuspectTimeout, func() { m.confirmDead(target.ID) })
+}
+
+// confirmDead transitions a still-suspect member to Dead once the
+// suspicion timeout has elapsed without it refuting via a higher
+// incarnation Alive update.
+func (m *Membership) confirmDead(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mem, ok := m.members[id]
+	if !ok || mem.State != Suspect {
+		return
+	}
+	mem.State = Dead
+	mem.DeadSince = time.Now()
+	log.Info("membership: marking %s dead", mem.ID)
+	m.queueUpdate(Update{ID: mem.ID, Addr: mem.Addr, State: Dead, Incarnation: mem.Incarnation})
+}
+
+// applyUpdates merges piggybacked updates into our local view, honoring
+// SWIM's incarnation rule: an update only overrides what we already know if
+// its incarnation is at least as high.
+func (m *Membership) applyUpdates(updates []Update) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range updates {
+		if u.ID == m.self.ID {
+			m.refuteSelf(u)
+			continue
+		}
+
+		mem, ok := m.members[u.ID]
+		if !ok {
+			m.members[u.ID] = &Member{ID: u.ID, Addr: u.Addr, State: u.State, Incarnation: u.Incarnation, LastSeen: time.Now()}
+			continue
+		}
+		if u.Incarnation < mem.Incarnation {
+			continue
+		}
+		mem.State = u.State
+		mem.Incarnation = u.Incarnation
+		if u.State == Alive {
+			mem.LastSeen = time.Now()
+		}
+		if u.State == Dead {
+			mem.DeadSince = time.Now()
+		}
+	}
+}
+
+// refuteSelf handles an incoming update about our own ID. Suspect/Dead
+// gossip about ourselves can only be wrong, so we bump our own incarnation
+// past the update's and rebroadcast Alive at the new incarnation - per the
+// incarnation rule above, that beats the stale update everywhere else it
+// has already spread. Must be called with m.mu held.
+func (m *Membership) refuteSelf(u Update) {
+	if u.State == Alive || u.Incarnation < m.self.Incarnation {
+		return
+	}
+	m.self.Incarnation = u.Incarnation + 1
+	log.Info("membership: refuting %s gossip about self, bumping incarnation to %d", u.State, m.self.Incarnation)
+	m.queueUpdate(Update{ID: m.self.ID, Addr: m.self.Addr, State: Alive, Incarnation: m.self.Incarnation})
+}
+
+// queueUpdate encodes u and pushes it onto the outbound queue so it can be
+// piggybacked on the next outgoing ping.
+func (m *Membership) queueUpdate(u Update) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(u); err != nil {
+		log.Error("membership: failed to encode update for %s: %v", u.ID, err)
+		return
+	}
+	m.outq.Push(buf.Bytes())
+}
+
+// drainPending pops every update currently queued so they can be
+// piggybacked on the next outgoing ping.
+func (m *Membership) drainPending() []Update {
+	var updates []Update
+	for {
+		msg, ok := m.outq.Pop()
+		if !ok {
+			break
+		}
+		var u Update
+		if err := gob.NewDecoder(bytes.NewReader(msg)).Decode(&u); err != nil {
+			log.Error("membership: failed to decode queued update: %v", err)
+			continue
+		}
+		updates = append(updates, u)
+	}
+	return updates
+}
+
+// ReclaimableBy reports whether a new node may take over id's name/ID,
+// per SwarmConfig.DeadNodeReclaimAfter.
+func (m *Membership) ReclaimableBy(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mem, ok := m.members[id]
+	if !ok {
+		return true
+	}
+	return mem.State == Dead && time.Since(mem.DeadSince) >= m.config.DeadNodeReclaimAfter
+}
+
+func (m *Membership) randomMember() *Member {
+	return firstOf(m.randomMembersExcept("", 1))
+}
+
+// randomMembersExcept returns up to n members that are not Dead (Alive or
+// Suspect), other than exceptID. Real randomness is left to the caller's
+// transport layer selection; here we just take an arbitrary subset, since
+// map iteration order in Go is already randomized per range.
+func (m *Membership) randomMembersExcept(exceptID string, n int) []*Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []*Member
+	for id, mem := range m.members {
+		if id == exceptID || mem.State == Dead {
+			continue
+		}
+		out = append(out, mem)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+func firstOf(members []*Member) *Member {
+	if len(members) == 0 {
+		return nil
+	}
+	return members[0]
+}
@@ -0,0 +1,175 @@
+package membership
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// fakeTransport lets tests script direct/indirect ping outcomes without any
+// real networking.
+type fakeTransport struct {
+	acks map[string]bool
+}
+
+func (f *fakeTransport) Ping(addr string, updates []Update, timeout time.Duration) ([]Update, bool) {
+	return nil, f.acks[addr]
+}
+
+func (f *fakeTransport) IndirectPing(via, target string, updates []Update, timeout time.Duration) bool {
+	return f.acks[target]
+}
+
+func TestMarkSuspectThenConfirmDead(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.Add("peer", "peer-addr")
+
+	mem := m.members["peer"]
+	m.markSuspect(mem)
+
+	if got := m.members["peer"].State; got != Suspect {
+		t.Fatalf("expected peer to be Suspect, got %v", got)
+	}
+
+	m.confirmDead("peer")
+	if got := m.members["peer"].State; got != Dead {
+		t.Fatalf("expected peer to be Dead, got %v", got)
+	}
+	if m.members["peer"].DeadSince.IsZero() {
+		t.Fatalf("expected DeadSince to be set")
+	}
+}
+
+func TestConfirmDeadIsNoopIfNoLongerSuspect(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.Add("peer", "peer-addr")
+
+	// peer is still Alive, never went through markSuspect - confirmDead
+	// must not fire on a stale timer for a peer that already recovered.
+	m.confirmDead("peer")
+	if got := m.members["peer"].State; got != Alive {
+		t.Fatalf("expected peer to remain Alive, got %v", got)
+	}
+}
+
+func TestAddRejectsIDStillInReclaimWindow(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{DeadNodeReclaimAfter: time.Hour})
+	m.Add("peer", "old-addr")
+	m.members["peer"].State = Dead
+	m.members["peer"].DeadSince = time.Now()
+
+	m.Add("peer", "new-addr")
+	if got := m.members["peer"].Addr; got != "old-addr" {
+		t.Fatalf("expected peer to stay dead at old-addr within the reclaim window, got %v", got)
+	}
+}
+
+func TestAddReclaimsIDPastReclaimWindow(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{DeadNodeReclaimAfter: time.Hour})
+	m.Add("peer", "old-addr")
+	m.members["peer"].State = Dead
+	m.members["peer"].DeadSince = time.Now().Add(-2 * time.Hour)
+
+	m.Add("peer", "new-addr")
+	if got := m.members["peer"].State; got != Alive {
+		t.Fatalf("expected reclaimed peer to be Alive, got %v", got)
+	}
+	if got := m.members["peer"].Addr; got != "new-addr" {
+		t.Fatalf("expected reclaimed peer to take over the new address, got %v", got)
+	}
+}
+
+func TestApplyUpdatesHonorsIncarnation(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.Add("peer", "peer-addr")
+	m.members["peer"].Incarnation = 5
+	m.members["peer"].State = Suspect
+
+	// A stale Alive refutation (lower incarnation) must be ignored...
+	m.applyUpdates([]Update{{ID: "peer", Addr: "peer-addr", State: Alive, Incarnation: 4}})
+	if got := m.members["peer"].State; got != Suspect {
+		t.Fatalf("expected stale update to be ignored, got state %v", got)
+	}
+
+	// ...but a refutation at a higher incarnation must win.
+	m.applyUpdates([]Update{{ID: "peer", Addr: "peer-addr", State: Alive, Incarnation: 6}})
+	if got := m.members["peer"].State; got != Alive {
+		t.Fatalf("expected higher-incarnation update to apply, got state %v", got)
+	}
+}
+
+func TestDrainPendingRoundTripsThroughOutboundQueue(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.Add("peer", "peer-addr")
+
+	m.markAlive("peer")
+	if got := m.outq.Len(); got != 1 {
+		t.Fatalf("expected markAlive to push one update onto the outbound queue, got %d queued", got)
+	}
+
+	updates := m.drainPending()
+	if len(updates) != 1 || updates[0].ID != "peer" || updates[0].State != Alive {
+		t.Fatalf("unexpected drained updates: %+v", updates)
+	}
+	if m.outq.Len() != 0 {
+		t.Fatalf("expected outbound queue to be empty after drain")
+	}
+}
+
+func TestApplyUpdatesRefutesSuspectGossipAboutSelf(t *testing.T) {
+	m := New(Member{ID: "self", Addr: "self-addr"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.self.Incarnation = 3
+
+	// Some peer gossips that we ourselves are Suspect. We must refute by
+	// bumping our own incarnation past the update's and rebroadcasting
+	// Alive, not silently accept it.
+	m.applyUpdates([]Update{{ID: "self", Addr: "self-addr", State: Suspect, Incarnation: 3}})
+
+	if m.self.Incarnation <= 3 {
+		t.Fatalf("expected self incarnation to be bumped past the suspect update, got %d", m.self.Incarnation)
+	}
+	if _, ok := m.members["self"]; ok {
+		t.Fatalf("self must never be inserted into m.members")
+	}
+
+	updates := m.drainPending()
+	if len(updates) != 1 || updates[0].ID != "self" || updates[0].State != Alive || updates[0].Incarnation != m.self.Incarnation {
+		t.Fatalf("expected a queued Alive rebroadcast for self at the bumped incarnation, got %+v", updates)
+	}
+}
+
+func TestApplyUpdatesIgnoresStaleSuspectGossipAboutSelf(t *testing.T) {
+	m := New(Member{ID: "self", Addr: "self-addr"}, &fakeTransport{}, nodeconfig.SwarmConfig{})
+	m.self.Incarnation = 5
+
+	// A Suspect update carrying an incarnation lower than what we're
+	// already on is stale and must not cause us to bump again.
+	m.applyUpdates([]Update{{ID: "self", Addr: "self-addr", State: Suspect, Incarnation: 2}})
+
+	if m.self.Incarnation != 5 {
+		t.Fatalf("expected stale suspect gossip about self to be ignored, got incarnation %d", m.self.Incarnation)
+	}
+	if m.outq.Len() != 0 {
+		t.Fatalf("expected no rebroadcast to be queued for stale gossip")
+	}
+}
+
+func TestIndirectProbeRespectsAdmissionCap(t *testing.T) {
+	m := New(Member{ID: "self"}, &fakeTransport{acks: map[string]bool{"target-addr": true}}, nodeconfig.SwarmConfig{RandomConnections: 3})
+	m.admission = NewAdmissionController(1) // cap fan-out to a single in-flight probe
+
+	m.Add("target", "target-addr")
+	m.Add("helper-a", "helper-a-addr")
+	m.Add("helper-b", "helper-b-addr")
+	m.Add("helper-c", "helper-c-addr")
+
+	if ok := m.indirectProbe(m.members["target"], nil); !ok {
+		t.Fatalf("expected indirect probe to succeed")
+	}
+	// TryAdmit/Release must leave the controller balanced once probing is
+	// done, regardless of how many helpers were actually admitted.
+	if !m.admission.TryAdmit() {
+		t.Fatalf("expected admission controller to have a free slot after indirectProbe returned")
+	}
+}
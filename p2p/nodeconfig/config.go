@@ -36,6 +36,33 @@ type Config struct {
 	NetworkID     int         `mapstructure:"network-id"`
 	SwarmConfig   SwarmConfig `mapstructure:"swarm"`
 	TimeConfig    TimeConfig
+	StorageConfig StorageConfig
+
+	// UDPPort is the port the discovery protocol listens on for
+	// PING/PONG/FINDNODE/NODES messages.
+	UDPPort int `mapstructure:"udp-port"`
+
+	// DiscoveryEnabled turns on Kademlia/ENR-based peer discovery. When
+	// false, SwarmConfig.BootstrapNodes is the only source of peers.
+	DiscoveryEnabled bool `mapstructure:"discovery-enabled"`
+}
+
+// StorageConfig selects the merkle.Backend used to persist tree and user
+// data, and holds the per-backend settings for whichever one is selected.
+type StorageConfig struct {
+	StorageBackend string `mapstructure:"storage-backend"` // "mem", "leveldb" or "sqlite"
+	LevelDB        LevelDBConfig
+	SQLite         SQLiteConfig
+}
+
+// LevelDBConfig holds settings for the leveldb storage backend.
+type LevelDBConfig struct {
+	Path string `mapstructure:"leveldb-path"`
+}
+
+// SQLiteConfig holds settings for the sqlite storage backend.
+type SQLiteConfig struct {
+	Path string `mapstructure:"sqlite-path"`
 }
 
 // SwarmConfig specifies swarm config params.
@@ -45,6 +72,10 @@ type SwarmConfig struct {
 	RoutingTableAlpha      int  `mapstructure:"swarm-rtalpha"`
 	RandomConnections      int  `mapstructure:"swarm-randcon"`
 	BootstrapNodes         []string
+
+	// DeadNodeReclaimAfter is how long a peer must stay in the `dead`
+	// membership state before a new node is allowed to reuse its name/ID.
+	DeadNodeReclaimAfter time.Duration
 }
 
 // TimeConfig specifies the timesync params for ntp.
@@ -73,9 +104,13 @@ func DefaultConfig() Config {
 		RoutingTableAlpha:      3,
 		RandomConnections:      5,
 		BootstrapNodes: []string{ // these should be the spacemesh foundation bootstrap nodes
-			"125.0.0.1:3572/iaMujEYTByKcjMZWMqg79eJBGMDm8ADsWZFdouhpfeKj",
-			"125.0.0.1:3763/x34UDdiCBAsXmLyMMpPQzs313B9UDeHNqFpYsLGfaFvm",
+			// host:port/pubkey, where pubkey is hex(uncompressed P256 point) -
+			// the format discovery.ParseBootstrapNodes expects, see that doc
+			// comment.
+			"125.0.0.1:3572/04f042eb590fb0b50784f586e2a7bfacb7fc11ba39e2e8a2af18569b8a5c7fa61164a069bde177c545493a5ebf4703fec0fb3705dc94a41c1a76486721447f22ac",
+			"125.0.0.1:3763/0427031300bc968d8d52d93e7bf9abec47f73573cc361ed6ce7a12ab1eccc90028341223954e51de2369a02917c1bb743f4587ee079814052e20bc8006b7a2b47e",
 		},
+		DeadNodeReclaimAfter: duration("24h"),
 	}
 
 	return Config{
@@ -88,5 +123,12 @@ func DefaultConfig() Config {
 		NetworkID:     int(TestNet),
 		SwarmConfig:   SwarmConfigValues,
 		TimeConfig:    TimeConfigValues,
+		StorageConfig: StorageConfig{
+			StorageBackend: "leveldb",
+			LevelDB:        LevelDBConfig{Path: "spacemesh-db"},
+			SQLite:         SQLiteConfig{Path: "spacemesh.sqlite"},
+		},
+		UDPPort:          7513,
+		DiscoveryEnabled: true,
 	}
 }
@@ -0,0 +1,51 @@
+package sync
+
+import (
+	"math/rand"
+
+	"github.com/spacemeshos/go-spacemesh/p2p/membership"
+)
+
+// Dialer opens an RPC-capable connection to an alive member's address.
+// It's the piece of the swarm layer that actually knows how to dial, kept
+// separate so this package doesn't need to depend on the full swarm.
+type Dialer interface {
+	Dial(addr string) (Peer, error)
+}
+
+// MembershipSwarm adapts a membership.Membership view plus a Dialer into
+// the Swarm interface the syncer needs, so sync rounds only ever target
+// peers the SWIM failure detector currently considers alive.
+type MembershipSwarm struct {
+	members *membership.Membership
+	dialer  Dialer
+}
+
+// NewMembershipSwarm creates a Swarm backed by members' current view and
+// dialer for turning an address into a usable Peer.
+func NewMembershipSwarm(members *membership.Membership, dialer Dialer) *MembershipSwarm {
+	return &MembershipSwarm{
+		members: members,
+		dialer:  dialer,
+	}
+}
+
+// RandomConnection picks a random alive member and dials it.
+func (s *MembershipSwarm) RandomConnection() (Peer, bool) {
+	var alive []membership.Member
+	for _, m := range s.members.Members() {
+		if m.State == membership.Alive {
+			alive = append(alive, m)
+		}
+	}
+	if len(alive) == 0 {
+		return nil, false
+	}
+
+	m := alive[rand.Intn(len(alive))]
+	peer, err := s.dialer.Dial(m.Addr)
+	if err != nil {
+		return nil, false
+	}
+	return peer, true
+}
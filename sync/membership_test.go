@@ -0,0 +1,67 @@
+package sync_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/p2p/membership"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+	msync "github.com/spacemeshos/go-spacemesh/sync"
+)
+
+// noopTransport satisfies membership.Transport without any real networking;
+// these tests only exercise MembershipSwarm's dial path, not SWIM probing.
+type noopTransport struct{}
+
+func (noopTransport) Ping(addr string, updates []membership.Update, timeout time.Duration) ([]membership.Update, bool) {
+	return nil, false
+}
+
+func (noopTransport) IndirectPing(via, target string, updates []membership.Update, timeout time.Duration) bool {
+	return false
+}
+
+// dummyPeer satisfies msync.Peer for tests that only care whether a dial
+// happened, not what comes back over it.
+type dummyPeer struct{}
+
+func (dummyPeer) GetMerkleNode(prefix string) (*merkle.NodeInfo, error) { return nil, nil }
+
+type fakeDialer struct {
+	dialed []string
+	fail   bool
+}
+
+func (d *fakeDialer) Dial(addr string) (msync.Peer, error) {
+	d.dialed = append(d.dialed, addr)
+	if d.fail {
+		return nil, errors.New("dial failed")
+	}
+	return dummyPeer{}, nil
+}
+
+func TestRandomConnectionDialsAnAliveMember(t *testing.T) {
+	members := membership.New(membership.Member{ID: "self"}, noopTransport{}, nodeconfig.SwarmConfig{})
+	members.Add("peer", "peer-addr")
+
+	dialer := &fakeDialer{}
+	swarm := msync.NewMembershipSwarm(members, dialer)
+
+	if _, ok := swarm.RandomConnection(); !ok {
+		t.Fatalf("expected RandomConnection to succeed against an alive member")
+	}
+	if len(dialer.dialed) != 1 || dialer.dialed[0] != "peer-addr" {
+		t.Fatalf("expected a dial to peer-addr, got %v", dialer.dialed)
+	}
+}
+
+func TestRandomConnectionFailsWithNoAliveMembers(t *testing.T) {
+	members := membership.New(membership.Member{ID: "self"}, noopTransport{}, nodeconfig.SwarmConfig{})
+	swarm := msync.NewMembershipSwarm(members, &fakeDialer{})
+
+	if _, ok := swarm.RandomConnection(); ok {
+		t.Fatalf("expected RandomConnection to fail with no members")
+	}
+}
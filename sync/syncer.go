@@ -0,0 +1,173 @@
+// Package sync implements anti-entropy synchronization of a node's merkle
+// tree against the trees held by its swarm peers, so that replicated k/v
+// data converges without either side doing a full scan.
+package sync
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/log"
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+)
+
+// Peer is the subset of a remote node's RPC surface that the syncer needs.
+type Peer interface {
+	GetMerkleNode(prefix string) (*merkle.NodeInfo, error)
+}
+
+// Swarm provides access to the currently connected peers.
+type Swarm interface {
+	RandomConnection() (Peer, bool)
+}
+
+// Tree is the subset of merkleTreeImp's exported API the syncer needs.
+type Tree interface {
+	RootHash() ([]byte, error)
+	GetMerkleNode(prefix string) (*merkle.NodeInfo, error)
+	Put(k, v []byte) error
+}
+
+// MerkleSyncer periodically reconciles the local tree against a random
+// swarm peer's tree, descending the differing branches until it isolates
+// and fetches the missing leaves.
+type MerkleSyncer struct {
+	tree     Tree
+	swarm    Swarm
+	interval time.Duration
+	quit     chan struct{}
+}
+
+// NewMerkleSyncer creates a syncer that runs a reconciliation round every
+// interval against a random peer drawn from swarm.
+func NewMerkleSyncer(tree Tree, swarm Swarm, config nodeconfig.SwarmConfig, interval time.Duration) *MerkleSyncer {
+	return &MerkleSyncer{
+		tree:     tree,
+		swarm:    swarm,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start launches the background sync worker. It returns immediately; call
+// Stop to shut it down.
+func (s *MerkleSyncer) Start() {
+	go s.loop()
+}
+
+// Stop terminates the background sync worker.
+func (s *MerkleSyncer) Stop() {
+	close(s.quit)
+}
+
+func (s *MerkleSyncer) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			peer, ok := s.swarm.RandomConnection()
+			if !ok {
+				continue
+			}
+			if err := s.syncWithPeer(peer); err != nil {
+				log.Error("merkle sync round failed: %v", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// syncWithPeer reconciles the local tree with peer's tree, recursing into
+// any prefix whose (prefix, hash) pair disagrees between the two sides.
+func (s *MerkleSyncer) syncWithPeer(peer Peer) error {
+	return s.reconcile("", peer)
+}
+
+// SyncOnce runs a single reconciliation round against peer outside of the
+// background ticker, e.g. to force a sync on demand or in a test.
+func (s *MerkleSyncer) SyncOnce(peer Peer) error {
+	return s.syncWithPeer(peer)
+}
+
+// reconcile compares the local and remote nodes at prefix and, for any
+// branch children whose hashes disagree, recurses one level deeper. Leaves
+// found only on the remote side (or with a different value) are fetched and
+// applied via Put, as is a branch's own value (see upsert's "whole path
+// matched" case) when it differs or is missing locally.
+func (s *MerkleSyncer) reconcile(prefix string, peer Peer) error {
+	remote, err := peer.GetMerkleNode(prefix)
+	if err != nil {
+		return err
+	}
+
+	local, err := s.tree.GetMerkleNode(prefix)
+	if err != nil && err != merkle.PrefixNotFoundError {
+		return err
+	}
+
+	if remote.Leaf {
+		if local == nil || !local.Leaf || string(local.Value) != string(remote.Value) {
+			k, err := hex.DecodeString(remote.Key)
+			if err != nil {
+				return err
+			}
+			// Value is sha256(v) for large values (see merkle.Put) - Put
+			// back the real payload from LargeValue so it lands in the
+			// user-db, not the 32-byte digest. Putting the digest would
+			// still converge the merkle root, but would leave the actual
+			// value unreplicated.
+			v := remote.Value
+			if remote.LargeValue != nil {
+				v = remote.LargeValue
+			}
+			return s.tree.Put(k, v)
+		}
+		return nil
+	}
+
+	if remote.HasValue {
+		if local == nil || !local.HasValue || string(local.Value) != string(remote.Value) {
+			k, err := hex.DecodeString(remote.Prefix)
+			if err != nil {
+				return err
+			}
+			// Same digest/large-value convention as the leaf case above: the
+			// branch's own value may itself be sha256(v) for a large v.
+			v := remote.Value
+			if remote.LargeValue != nil {
+				v = remote.LargeValue
+			}
+			if err := s.tree.Put(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i < 16; i++ {
+		remoteChild := remote.Children[i]
+		var localChild []byte
+		if local != nil {
+			localChild = local.Children[i]
+		}
+		if string(remoteChild) == string(localChild) {
+			continue // this sub-tree already matches, no need to descend
+		}
+		if string(remoteChild) == string(merkle.EmptyNodeHash) {
+			continue // peer has nothing here, nothing to fetch
+		}
+		if err := s.reconcile(remote.Prefix+string(hexDigit(i)), peer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hexDigit(i int) byte {
+	const digits = "0123456789abcdef"
+	return digits[i]
+}
@@ -0,0 +1,136 @@
+package sync_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/merkle"
+	"github.com/spacemeshos/go-spacemesh/merkle/backends"
+	"github.com/spacemeshos/go-spacemesh/p2p/nodeconfig"
+	msync "github.com/spacemeshos/go-spacemesh/sync"
+)
+
+// TestSyncReplicatesLargeValue guards against the bug where a synced leaf
+// whose tree-stored value was sha256(v) (see merkle.Put's >32-byte case)
+// got written locally as-is, converging the merkle root without ever
+// replicating the real payload into the local user-db.
+func TestSyncReplicatesLargeValue(t *testing.T) {
+	remote := merkle.NewMerkleTree(backends.NewMemBackend())
+	local := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	k := []byte("big-key")
+	v := bytes.Repeat([]byte("x"), 64) // > 32 bytes, stored as sha256(v) in the tree
+	if err := remote.Put(k, v); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	syncer := msync.NewMerkleSyncer(local, nil, nodeconfig.SwarmConfig{}, time.Minute)
+	if err := syncer.SyncOnce(remote); err != nil {
+		t.Fatalf("SyncOnce failed: %v", err)
+	}
+
+	got, _, err := local.Get(k)
+	if err != nil {
+		t.Fatalf("Get failed on local tree after sync: %v", err)
+	}
+	if !bytes.Equal(got, v) {
+		t.Fatalf("expected synced value %q, got %q", v, got)
+	}
+
+	remoteRoot, err := remote.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+	localRoot, err := local.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+	if !bytes.Equal(remoteRoot, localRoot) {
+		t.Fatalf("expected local and remote roots to converge after sync")
+	}
+}
+
+// TestSyncReplicatesBranchOwnValue guards against the bug where reconcile
+// only ever compared a branch's Children hashes, never the branch's own
+// value (set when a key's whole path ends exactly at that branch - see
+// merkle upsert's "whole path matched" case). Two trees differing only in
+// such a value, with all 16 children matching, would otherwise never
+// converge.
+func TestSyncReplicatesBranchOwnValue(t *testing.T) {
+	remote := merkle.NewMerkleTree(backends.NewMemBackend())
+	local := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	// Two sibling keys sharing the 1-byte prefix 0x12, diverging at the next
+	// nibble, so both trees grow an ext+branch pair at that prefix.
+	if err := remote.Put([]byte{0x12, 0x30}, []byte("value-a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := remote.Put([]byte{0x12, 0x50}, []byte("value-b")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := local.Put([]byte{0x12, 0x30}, []byte("value-a")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := local.Put([]byte{0x12, 0x50}, []byte("value-b")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Only remote gets a value stored directly on that shared branch.
+	k := []byte{0x12}
+	v := []byte("branch-value")
+	if err := remote.Put(k, v); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	syncer := msync.NewMerkleSyncer(local, nil, nodeconfig.SwarmConfig{}, time.Minute)
+	if err := syncer.SyncOnce(remote); err != nil {
+		t.Fatalf("SyncOnce failed: %v", err)
+	}
+
+	got, _, err := local.Get(k)
+	if err != nil {
+		t.Fatalf("Get failed on local tree after sync: %v", err)
+	}
+	if !bytes.Equal(got, v) {
+		t.Fatalf("expected synced branch value %q, got %q", v, got)
+	}
+
+	remoteRoot, err := remote.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+	localRoot, err := local.RootHash()
+	if err != nil {
+		t.Fatalf("RootHash failed: %v", err)
+	}
+	if !bytes.Equal(remoteRoot, localRoot) {
+		t.Fatalf("expected local and remote roots to converge after sync")
+	}
+}
+
+// TestSyncShortValue covers the common case alongside the large-value one
+// above: a short value round-trips unchanged.
+func TestSyncShortValue(t *testing.T) {
+	remote := merkle.NewMerkleTree(backends.NewMemBackend())
+	local := merkle.NewMerkleTree(backends.NewMemBackend())
+
+	k := []byte("small-key")
+	v := []byte("small-value")
+	if err := remote.Put(k, v); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	syncer := msync.NewMerkleSyncer(local, nil, nodeconfig.SwarmConfig{}, time.Minute)
+	if err := syncer.SyncOnce(remote); err != nil {
+		t.Fatalf("SyncOnce failed: %v", err)
+	}
+
+	got, _, err := local.Get(k)
+	if err != nil {
+		t.Fatalf("Get failed on local tree after sync: %v", err)
+	}
+	if !bytes.Equal(got, v) {
+		t.Fatalf("expected synced value %q, got %q", v, got)
+	}
+}